@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"pubmed-api/internal/platform"
+	"pubmed-api/internal/repo"
+)
+
+// database is a repository backend selected by DB_DRIVER
+type database interface {
+	repo.ArticleRepository
+	Migrate(ctx context.Context) (int, error)
+	MigrationStatus(ctx context.Context) ([]repo.MigrationStatus, error)
+	Close() error
+}
+
+// openDatabase opens the repository configured by cfg, applying pending
+// schema migrations when migrate is set
+func openDatabase(cfg *platform.Config, logger *slog.Logger, migrate bool) (database, error) {
+	switch cfg.DBDriver {
+	case platform.DBDriverPostgres:
+		var opts []repo.PostgresOption
+		if !migrate {
+			opts = append(opts, repo.WithoutPostgresMigrations())
+		}
+		return repo.NewPostgresRepository(cfg.DBURL, logger, opts...)
+	case platform.DBDriverMemory:
+		return repo.NewMemoryRepository(logger), nil
+	}
+
+	var opts []repo.SQLiteOption
+	if !migrate {
+		opts = append(opts, repo.WithoutMigrations())
+	}
+	return repo.NewSQLiteRepository(cfg.DBPath, logger, opts...)
+}
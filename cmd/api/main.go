@@ -25,10 +25,14 @@ func main() {
 
 	// Initialize logger
 	logger := platform.NewLogger(cfg.LogLevel)
-	logger.Info("starting pubmed-api", "version", "1.0.0", "port", cfg.Port)
+	logger.Info("starting pubmed-api", "version", "1.0.0", "port", cfg.Port, "db_driver", cfg.DBDriver)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, logger, os.Args[2:]))
+	}
 
 	// Initialize repository
-	repository, err := repo.NewSQLiteRepository(cfg.DBPath, logger)
+	repository, err := openDatabase(cfg, logger, cfg.DBAutoMigrate)
 	if err != nil {
 		logger.Error("failed to create repository", "error", err)
 		os.Exit(1)
@@ -36,16 +40,40 @@ func main() {
 	defer repository.Close()
 
 	// Load data
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.LoadTimeout)
 	defer cancel()
 
-	if err := platform.LoadArticles(ctx, repository, cfg, logger); err != nil {
+	if !cfg.DBAutoMigrate {
+		statuses, err := repository.MigrationStatus(ctx)
+		if err != nil {
+			logger.Error("failed to check database schema", "error", err)
+			os.Exit(1)
+		}
+		if pending := repo.PendingMigrations(statuses); pending > 0 {
+			logger.Error("database schema is out of date, run the migrate subcommand", "pending", pending)
+			os.Exit(1)
+		}
+	}
+
+	loadReport, err := platform.LoadArticles(ctx, repository, cfg, logger)
+	if err != nil {
 		logger.Error("failed to load articles", "error", err)
 		os.Exit(1)
 	}
 
+	// Load the optional MeSH hierarchy used to explode MeSH filters
+	serviceOpts := []service.Option{service.WithLoadReport(loadReport)}
+	if cfg.MeshTreePath != "" {
+		meshTree, err := platform.LoadMeshTree(cfg.MeshTreePath, logger)
+		if err != nil {
+			logger.Error("failed to load MeSH tree", "error", err)
+			os.Exit(1)
+		}
+		serviceOpts = append(serviceOpts, service.WithMeshTree(meshTree))
+	}
+
 	// Initialize service
-	articleService := service.NewArticleService(repository)
+	articleService := service.NewArticleService(repository, serviceOpts...)
 
 	// Initialize HTTP router
 	router := httphandler.NewRouter(articleService, logger)
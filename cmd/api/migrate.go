@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"pubmed-api/internal/platform"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrate implements the migrate subcommand and returns the exit code:
+//
+//	pubmed-api migrate          apply pending schema migrations
+//	pubmed-api migrate status   list the migrations and when each was applied
+func runMigrate(cfg *platform.Config, logger *slog.Logger, args []string) int {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if command != "up" && command != "status" {
+		fmt.Fprintf(os.Stderr, "usage: %s migrate [up|status]\n", os.Args[0])
+		return 2
+	}
+
+	repository, err := openDatabase(cfg, logger, false)
+	if err != nil {
+		logger.Error("failed to open database", "error", err)
+		return 1
+	}
+	defer repository.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.LoadTimeout)
+	defer cancel()
+
+	if command == "up" {
+		applied, err := repository.Migrate(ctx)
+		if err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			return 1
+		}
+		logger.Info("database schema is up to date", "applied", applied)
+		return 0
+	}
+
+	statuses, err := repository.MigrationStatus(ctx)
+	if err != nil {
+		logger.Error("failed to read database schema", "error", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if !status.AppliedAt.IsZero() {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, applied)
+	}
+	w.Flush()
+	return 0
+}
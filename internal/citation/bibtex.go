@@ -0,0 +1,76 @@
+package citation
+
+import (
+	"bufio"
+	"io"
+	"pubmed-api/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// BibTeXWriter writes articles as BibTeX @article entries keyed by PMID
+type BibTeXWriter struct {
+	w *bufio.Writer
+}
+
+// NewBibTeXWriter returns a writer of BibTeX entries to w
+func NewBibTeXWriter(w io.Writer) *BibTeXWriter {
+	return &BibTeXWriter{w: bufio.NewWriter(w)}
+}
+
+// bibtexEscaper escapes the characters BibTeX treats specially. Other
+// characters, including non-ASCII ones, are written as UTF-8.
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// Write writes one article as an @article entry
+func (b *BibTeXWriter) Write(article *domain.Article) error {
+	b.w.WriteString("@article{pmid" + article.PMID + ",\n")
+
+	authors := make([]string, 0, len(article.Authors))
+	for _, author := range article.Authors {
+		name := ParseName(author)
+		if name.Literal != "" {
+			// Braces keep BibTeX from splitting a collective name
+			authors = append(authors, "{"+bibtexEscaper.Replace(name.Literal)+"}")
+		} else {
+			authors = append(authors, bibtexEscaper.Replace(name.Inverted()))
+		}
+	}
+	b.field("author", strings.Join(authors, " and "))
+
+	b.field("title", bibtexEscaper.Replace(oneLine(article.Title)))
+	b.field("journal", bibtexEscaper.Replace(article.Journal))
+	if article.PubYear != 0 {
+		b.field("year", strconv.Itoa(article.PubYear))
+	}
+	b.field("abstract", bibtexEscaper.Replace(oneLine(article.Abstract)))
+	b.field("keywords", bibtexEscaper.Replace(strings.Join(article.MeshTerms, "; ")))
+	b.field("doi", bibtexEscaper.Replace(article.DOI))
+	b.field("pmid", article.PMID)
+	b.w.WriteString("}\n\n")
+	return b.w.Flush()
+}
+
+// Close ends the stream
+func (b *BibTeXWriter) Close() error {
+	return b.w.Flush()
+}
+
+// field writes an escaped field, skipping empty values
+func (b *BibTeXWriter) field(name, value string) {
+	if value == "" {
+		return
+	}
+	b.w.WriteString("  " + name + " = {" + value + "},\n")
+}
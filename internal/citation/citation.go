@@ -0,0 +1,112 @@
+// Package citation renders articles in the formats reference managers import:
+// RIS, BibTeX, CSL-JSON and MEDLINE text.
+package citation
+
+import (
+	"io"
+	"pubmed-api/internal/domain"
+	"strings"
+	"unicode"
+)
+
+// Writer serializes a stream of articles
+type Writer interface {
+	// Write writes one article, possibly buffering it
+	Write(article *domain.Article) error
+	// Close writes anything still buffered and ends the stream
+	Close() error
+}
+
+// Format is a citation format
+type Format struct {
+	// Name identifies the format in the format query parameter
+	Name string
+	// MediaType is the Content-Type of the format
+	MediaType string
+	// Extension is the usual file name extension, without the dot
+	Extension string
+	// NewWriter returns a writer of the format to w
+	NewWriter func(w io.Writer) Writer
+}
+
+// The supported formats
+var (
+	RIS = Format{
+		Name:      "ris",
+		MediaType: "application/x-research-info-systems",
+		Extension: "ris",
+		NewWriter: func(w io.Writer) Writer { return NewRISWriter(w) },
+	}
+	BibTeX = Format{
+		Name:      "bibtex",
+		MediaType: "application/x-bibtex",
+		Extension: "bib",
+		NewWriter: func(w io.Writer) Writer { return NewBibTeXWriter(w) },
+	}
+	CSLJSON = Format{
+		Name:      "csl-json",
+		MediaType: "application/vnd.citationstyles.csl+json",
+		Extension: "json",
+		NewWriter: func(w io.Writer) Writer { return NewCSLJSONWriter(w) },
+	}
+	MEDLINE = Format{
+		Name:      "medline",
+		MediaType: "application/nbib",
+		Extension: "nbib",
+		NewWriter: func(w io.Writer) Writer { return NewMedlineWriter(w) },
+	}
+)
+
+// Formats lists the supported formats
+var Formats = []Format{RIS, BibTeX, CSLJSON, MEDLINE}
+
+// Name is an author name split into its parts
+type Name struct {
+	Family string
+	// Initials are the initials of the given names, e.g. "JA"
+	Initials string
+	// Literal is set instead of the other fields for names that are not
+	// in the "Family Initials" form, such as collective authors
+	Literal string
+}
+
+// ParseName splits an author name in the form PubMed displays it, e.g.
+// "Smith JA" or "van der Berg K". Names without initials, such as
+// "POP Trial Investigators", are kept whole as a Literal.
+func ParseName(name string) Name {
+	name = strings.Join(strings.Fields(name), " ")
+
+	i := strings.LastIndex(name, " ")
+	if i < 0 {
+		return Name{Literal: name}
+	}
+
+	initials := name[i+1:]
+	if len(initials) > 3 || strings.IndexFunc(initials, func(r rune) bool { return !unicode.IsUpper(r) }) >= 0 {
+		return Name{Literal: name}
+	}
+	return Name{Family: name[:i], Initials: initials}
+}
+
+// Given returns the initials as given names, e.g. "J. A."
+func (n Name) Given() string {
+	given := make([]string, 0, len(n.Initials))
+	for _, r := range n.Initials {
+		given = append(given, string(r)+".")
+	}
+	return strings.Join(given, " ")
+}
+
+// Inverted returns the name as "Family, J. A.", or the literal name
+func (n Name) Inverted() string {
+	if n.Literal != "" {
+		return n.Literal
+	}
+	return n.Family + ", " + n.Given()
+}
+
+// oneLine joins the lines of s with spaces, for formats whose fields cannot
+// span lines
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
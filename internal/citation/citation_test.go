@@ -0,0 +1,119 @@
+package citation
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"pubmed-api/internal/domain"
+	"pubmed-api/internal/pubmed"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testArticles cover the fields each format has to escape or omit
+var testArticles = []*domain.Article{
+	{
+		PMID:      "31234567",
+		Title:     "Ibuprofen versus paracetamol for acute postoperative pain: a randomised trial of 100% {strength} & \"dose\"",
+		Abstract:  "BACKGROUND: Postoperative pain is common after day surgery.\nMETHODS: Adults were randomised to ibuprofen 400 mg or paracetamol 1 g in a trial_run with $5 <vouchers>.",
+		Authors:   []string{"Smith JA", "van der Berg K", "Müller H", "POP Trial Investigators"},
+		Journal:   "Br J Clin Pharmacol",
+		PubYear:   2020,
+		MeshTerms: []string{"Analgesics, Non-Narcotic", "Ibuprofen", "Pain, Postoperative"},
+		DOI:       "10.1111/bcp.14180",
+	},
+	{
+		PMID:    "9876543",
+		Title:   "[Ibuprofène chez l'enfant fébrile].",
+		Journal: "Pain Med Q",
+	},
+}
+
+func TestFormats_Golden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format.Name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := format.NewWriter(&buf)
+			for _, article := range testArticles {
+				require.NoError(t, w.Write(article))
+			}
+			require.NoError(t, w.Close())
+
+			golden := filepath.Join("testdata", "articles."+format.Name)
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), buf.String())
+		})
+	}
+}
+
+func TestFormats_Empty(t *testing.T) {
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		require.NoError(t, format.NewWriter(&buf).Close())
+		if format.Name == CSLJSON.Name {
+			assert.Equal(t, "[]\n", buf.String())
+		} else {
+			assert.Empty(t, buf.String(), format.Name)
+		}
+	}
+}
+
+func TestMedlineWriter_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewMedlineWriter(&buf)
+	for _, article := range testArticles {
+		require.NoError(t, w.Write(article))
+	}
+	require.NoError(t, w.Close())
+
+	citations, err := pubmed.ParseMedline(&buf)
+	require.NoError(t, err)
+	require.Len(t, citations, len(testArticles))
+
+	for i, citation := range citations {
+		want := *testArticles[i]
+		// Line breaks in the abstract do not survive the wrapping
+		want.Abstract = oneLine(want.Abstract)
+		got := citation.Article()
+		if len(want.Authors) == 0 {
+			want.Authors = []string{}
+		}
+		if len(want.MeshTerms) == 0 {
+			want.MeshTerms = []string{}
+		}
+		assert.Equal(t, &want, got)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     Name
+		inverted string
+	}{
+		{name: "Smith JA", want: Name{Family: "Smith", Initials: "JA"}, inverted: "Smith, J. A."},
+		{name: "van der Berg K", want: Name{Family: "van der Berg", Initials: "K"}, inverted: "van der Berg, K."},
+		{name: "Müller  H ", want: Name{Family: "Müller", Initials: "H"}, inverted: "Müller, H."},
+		{name: "POP Trial Investigators", want: Name{Literal: "POP Trial Investigators"}, inverted: "POP Trial Investigators"},
+		{name: "Martin", want: Name{Literal: "Martin"}, inverted: "Martin"},
+		{name: "World Health ORGS", want: Name{Literal: "World Health ORGS"}, inverted: "World Health ORGS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseName(tt.name)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.inverted, got.Inverted())
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"pubmed-api/internal/domain"
+	"strings"
+)
+
+// CSLJSONWriter writes articles as a CSL-JSON array, the input format of
+// Citation Style Language processors such as citeproc-js and pandoc
+type CSLJSONWriter struct {
+	w       io.Writer
+	written int
+}
+
+// NewCSLJSONWriter returns a writer of a CSL-JSON array to w
+func NewCSLJSONWriter(w io.Writer) *CSLJSONWriter {
+	return &CSLJSONWriter{w: w}
+}
+
+// cslItem is a CSL-JSON item of type article-journal
+type cslItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []cslName `json:"author,omitempty"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+	Issued         *cslDate  `json:"issued,omitempty"`
+	Abstract       string    `json:"abstract,omitempty"`
+	Keyword        string    `json:"keyword,omitempty"`
+	DOI            string    `json:"DOI,omitempty"`
+	PMID           string    `json:"PMID"`
+}
+
+type cslName struct {
+	Family  string `json:"family,omitempty"`
+	Given   string `json:"given,omitempty"`
+	Literal string `json:"literal,omitempty"`
+}
+
+type cslDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// Write writes one article as an element of the array, one per line
+func (c *CSLJSONWriter) Write(article *domain.Article) error {
+	item := cslItem{
+		ID:             article.PMID,
+		Type:           "article-journal",
+		Title:          article.Title,
+		ContainerTitle: article.Journal,
+		Abstract:       article.Abstract,
+		Keyword:        strings.Join(article.MeshTerms, "; "),
+		DOI:            article.DOI,
+		PMID:           article.PMID,
+	}
+	for _, author := range article.Authors {
+		name := ParseName(author)
+		item.Author = append(item.Author, cslName{Family: name.Family, Given: name.Given(), Literal: name.Literal})
+	}
+	if article.PubYear != 0 {
+		item.Issued = &cslDate{DateParts: [][]int{{article.PubYear}}}
+	}
+
+	var buf bytes.Buffer
+	if c.written == 0 {
+		buf.WriteString("[\n")
+	} else {
+		buf.WriteString(",\n")
+	}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(item); err != nil {
+		return err
+	}
+	// Leave the line open for the separator or the closing bracket
+	buf.Truncate(buf.Len() - 1)
+
+	c.written++
+	_, err := c.w.Write(buf.Bytes())
+	return err
+}
+
+// Close ends the array
+func (c *CSLJSONWriter) Close() error {
+	end := "\n]\n"
+	if c.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(c.w, end)
+	return err
+}
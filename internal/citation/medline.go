@@ -0,0 +1,72 @@
+package citation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"pubmed-api/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// MedlineWriter writes articles in the MEDLINE text format PubMed exports as
+// .nbib files. pubmed.MedlineReader reads it back.
+type MedlineWriter struct {
+	w *bufio.Writer
+}
+
+// NewMedlineWriter returns a writer of MEDLINE records to w
+func NewMedlineWriter(w io.Writer) *MedlineWriter {
+	return &MedlineWriter{w: bufio.NewWriter(w)}
+}
+
+// medlineWidth is the column long values are wrapped at
+const medlineWidth = 80
+
+// Write writes one article as a record. Authors are written as AU names,
+// the form domain articles keep them in.
+func (m *MedlineWriter) Write(article *domain.Article) error {
+	m.field("PMID", article.PMID)
+	if article.PubYear != 0 {
+		m.field("DP", strconv.Itoa(article.PubYear))
+	}
+	m.field("TI", article.Title)
+	if article.DOI != "" {
+		m.field("LID", article.DOI+" [doi]")
+	}
+	m.field("AB", article.Abstract)
+	for _, author := range article.Authors {
+		m.field("AU", author)
+	}
+	m.field("TA", article.Journal)
+	for _, term := range article.MeshTerms {
+		m.field("MH", term)
+	}
+	m.w.WriteString("\n")
+	return m.w.Flush()
+}
+
+// Close ends the stream
+func (m *MedlineWriter) Close() error {
+	return m.w.Flush()
+}
+
+// field writes a "TAG - value" field, wrapping long values onto continuation
+// lines indented by six spaces. Empty values are skipped.
+func (m *MedlineWriter) field(tag, value string) {
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		return
+	}
+
+	line := fmt.Sprintf("%-4s- %s", tag, words[0])
+	for _, word := range words[1:] {
+		if len(line)+1+len(word) > medlineWidth {
+			m.w.WriteString(line + "\n")
+			line = "      " + word
+		} else {
+			line += " " + word
+		}
+	}
+	m.w.WriteString(line + "\n")
+}
@@ -0,0 +1,54 @@
+package citation
+
+import (
+	"bufio"
+	"io"
+	"pubmed-api/internal/domain"
+	"strconv"
+)
+
+// RISWriter writes articles as RIS records, the tagged format of EndNote and
+// most other reference managers
+type RISWriter struct {
+	w *bufio.Writer
+}
+
+// NewRISWriter returns a writer of RIS records to w
+func NewRISWriter(w io.Writer) *RISWriter {
+	return &RISWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes one article as a journal article record
+func (r *RISWriter) Write(article *domain.Article) error {
+	r.field("TY", "JOUR")
+	r.field("AN", article.PMID)
+	r.field("TI", oneLine(article.Title))
+	for _, author := range article.Authors {
+		r.field("AU", ParseName(author).Inverted())
+	}
+	r.field("T2", article.Journal)
+	if article.PubYear != 0 {
+		r.field("PY", strconv.Itoa(article.PubYear))
+	}
+	r.field("AB", oneLine(article.Abstract))
+	for _, term := range article.MeshTerms {
+		r.field("KW", term)
+	}
+	r.field("DO", article.DOI)
+	r.field("UR", "https://pubmed.ncbi.nlm.nih.gov/"+article.PMID+"/")
+	r.w.WriteString("ER  - \n\n")
+	return r.w.Flush()
+}
+
+// Close ends the stream
+func (r *RISWriter) Close() error {
+	return r.w.Flush()
+}
+
+// field writes a tag line, skipping empty values
+func (r *RISWriter) field(tag, value string) {
+	if value == "" {
+		return
+	}
+	r.w.WriteString(tag + "  - " + value + "\n")
+}
@@ -1,5 +1,13 @@
 package domain
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
 // Article represents a PubMed article entity
 type Article struct {
 	PMID      string   `json:"pmid"`
@@ -12,28 +20,144 @@ type Article struct {
 	DOI       string   `json:"doi,omitempty"`
 }
 
+// MinPubYear is the earliest publication year accepted for an article
+const MinPubYear = 1700
+
+// doiPattern matches a DOI: the 10. directory prefix, a registrant code and
+// a suffix without whitespace
+var doiPattern = regexp.MustCompile(`^10\.\d{4,9}(\.\d+)*/\S+$`)
+
+// Validate checks that the article can be stored and served: it needs a
+// numeric PMID and a title, and a year and DOI when set must be plausible.
+// All problems found are reported together.
+func (a *Article) Validate() error {
+	var problems []string
+
+	switch {
+	case strings.TrimSpace(a.PMID) == "":
+		problems = append(problems, "pmid is empty")
+	case strings.IndexFunc(a.PMID, func(r rune) bool { return r < '0' || r > '9' }) >= 0:
+		problems = append(problems, fmt.Sprintf("pmid %q is not numeric", a.PMID))
+	}
+
+	if strings.TrimSpace(a.Title) == "" {
+		problems = append(problems, "title is empty")
+	}
+
+	// A zero year means the year is unknown
+	if maxYear := time.Now().Year() + 1; a.PubYear != 0 && (a.PubYear < MinPubYear || a.PubYear > maxYear) {
+		problems = append(problems, fmt.Sprintf("pub_year %d is outside %d-%d", a.PubYear, MinPubYear, maxYear))
+	}
+
+	if a.DOI != "" && !doiPattern.MatchString(a.DOI) {
+		problems = append(problems, fmt.Sprintf("doi %q is malformed", a.DOI))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 // SearchFilters represents search and filter parameters
 type SearchFilters struct {
-	Query    string
-	Year     *int
-	Journal  string
-	Author   string
+	// Query is the raw q parameter. Repositories treat it as plain words that
+	// must all match unless Expr holds its parsed form.
+	Query string
+	Expr  QueryNode
+	// YearFrom and YearTo bound the publication year, inclusive
+	YearFrom *int
+	YearTo   *int
+	// Journals matches articles published in any of the journals
+	Journals []string
+	// Authors matches author names by substring, combined by AuthorOperator
+	Authors        []string
+	AuthorOperator Operator
+	// Mesh matches articles indexed with MeSH terms (exact, ignoring case),
+	// combined by MeshOperator
+	Mesh         []string
+	MeshOperator Operator
+	// MeshExplode asks for each Mesh term to also match its narrower terms in
+	// the MeSH hierarchy. The service fills MeshExpansions with the narrower
+	// terms of each Mesh term it knows about.
+	MeshExplode    bool
+	MeshExpansions map[string][]string
+	// IncludeMesh extends untagged query terms to MeSH terms
+	IncludeMesh bool
+
 	Page     int
 	PageSize int
 	Sort     string
+	// Cursor is the raw cursor parameter. The service decodes it into After,
+	// which switches the search from Page to keyset pagination: the page then
+	// holds the PageSize articles that sort after it.
+	Cursor string
+	After  *Cursor
+	// Facets lists the facets to count over the filtered result set
+	Facets []string
 }
 
-// SearchResult represents paginated search results
+// Operator combines the values of a multi-valued filter
+type Operator string
+
+// Supported filter operators
+const (
+	OperatorAnd Operator = "and"
+	OperatorOr  Operator = "or"
+)
+
+// Facet names accepted in SearchFilters.Facets
+const (
+	FacetJournal = "journal"
+	FacetYear    = "year"
+	FacetMesh    = "mesh"
+	FacetAuthor  = "author"
+)
+
+// SearchHit is an article matched by a search together with its relevance
+// score. Higher scores are better; the score is 0 when there is no query.
+type SearchHit struct {
+	*Article
+	Score float64 `json:"score,omitempty"`
+}
+
+// SearchResult represents paginated search results. Keyset pages have no
+// page number and are not counted, so Page is 0 and Total nil.
 type SearchResult struct {
-	Items    []*Article `json:"items"`
-	Page     int        `json:"page"`
-	PageSize int        `json:"page_size"`
-	Total    int        `json:"total"`
-	TookMs   int64      `json:"took_ms"`
+	Items    []*SearchHit `json:"items"`
+	Page     int          `json:"page,omitempty"`
+	PageSize int          `json:"page_size"`
+	Total    *int         `json:"total,omitempty"`
+	TookMs   int64        `json:"took_ms"`
+	// NextCursor positions the page after this one; nil on the last page
+	NextCursor *Cursor `json:"next_cursor,omitempty"`
+	// Facets maps each requested facet to its most frequent values
+	Facets map[string][]FacetCount `json:"facets,omitempty"`
+}
+
+// FacetCount is the number of matching articles with a facet value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// BatchGetResult holds the articles found by a lookup of several PMIDs, in
+// the order they were requested, and the requested PMIDs that were not found
+type BatchGetResult struct {
+	Articles []*Article `json:"articles"`
+	Missing  []string   `json:"missing"`
+}
+
+// FormattedCitation is an article cited in a citation style
+type FormattedCitation struct {
+	PMID     string `json:"pmid"`
+	Style    string `json:"style"`
+	Citation string `json:"citation"`
 }
 
 // Stats represents aggregate statistics
 type Stats struct {
+	Total         int            `json:"total"`
 	TopJournals   []JournalCount `json:"top_journals"`
 	YearHistogram map[int]int    `json:"year_histogram"`
 }
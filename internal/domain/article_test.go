@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestArticle_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		article Article
+		wantErr string
+	}{
+		{name: "valid", article: Article{PMID: "31234567", Title: "Title", PubYear: 2020, DOI: "10.1111/bcp.14180"}},
+		{name: "unknown year", article: Article{PMID: "1", Title: "Title"}},
+		{name: "sub-prefix DOI", article: Article{PMID: "1", Title: "Title", DOI: "10.1000.10/abc(12)"}},
+		{name: "empty pmid", article: Article{Title: "Title"}, wantErr: "pmid is empty"},
+		{name: "non-numeric pmid", article: Article{PMID: "PMC123", Title: "Title"}, wantErr: "not numeric"},
+		{name: "empty title", article: Article{PMID: "1", Title: "  "}, wantErr: "title is empty"},
+		{name: "early year", article: Article{PMID: "1", Title: "Title", PubYear: 99}, wantErr: "pub_year 99"},
+		{name: "future year", article: Article{PMID: "1", Title: "Title", PubYear: 3000}, wantErr: "pub_year 3000"},
+		{name: "malformed DOI", article: Article{PMID: "1", Title: "Title", DOI: "https://doi.org/10.1000/x"}, wantErr: "doi"},
+		{name: "DOI with spaces", article: Article{PMID: "1", Title: "Title", DOI: "10.1000/a b"}, wantErr: "doi"},
+		{name: "several problems", article: Article{PMID: "x"}, wantErr: "not numeric; title is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.article.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q but got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadReport_AddFailure(t *testing.T) {
+	var report LoadReport
+	for i := 1; i <= MaxLoadFailures+5; i++ {
+		report.AddFailure(LoadFailure{Line: i, Reason: "bad"})
+	}
+
+	if report.Rejected != MaxLoadFailures+5 {
+		t.Errorf("expected %d rejected but got %d", MaxLoadFailures+5, report.Rejected)
+	}
+	if len(report.Failures) != MaxLoadFailures || !report.FailuresTruncated {
+		t.Errorf("expected %d failures and truncation but got %d (%v)", MaxLoadFailures, len(report.Failures), report.FailuresTruncated)
+	}
+}
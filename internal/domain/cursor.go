@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor is a position in the sorted results of a search, used for keyset
+// pagination: the sort key and PMID of the last article of a page. The next
+// page holds the articles that sort after it.
+//
+// Cursors are opaque to clients; they encode as URL-safe base64 text.
+type Cursor struct {
+	// Sort is the sort order the cursor was issued for
+	Sort string
+	// Year is the publication year, for the year sorts
+	Year int
+	// Score is the relevance score, for the relevance sort
+	Score float64
+	PMID  string
+}
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorJSON is the encoded form of a Cursor
+type cursorJSON struct {
+	Sort  string  `json:"s"`
+	Year  int     `json:"y,omitempty"`
+	Score float64 `json:"r,omitempty"`
+	PMID  string  `json:"p"`
+}
+
+// MarshalText encodes the cursor as an opaque token
+func (c Cursor) MarshalText() ([]byte, error) {
+	data, err := json.Marshal(cursorJSON(c))
+	if err != nil {
+		return nil, err
+	}
+
+	text := make([]byte, base64.RawURLEncoding.EncodedLen(len(data)))
+	base64.RawURLEncoding.Encode(text, data)
+	return text, nil
+}
+
+// UnmarshalText decodes a token produced by MarshalText
+func (c *Cursor) UnmarshalText(text []byte) error {
+	data := make([]byte, base64.RawURLEncoding.DecodedLen(len(text)))
+	n, err := base64.RawURLEncoding.Decode(data, text)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+
+	var decoded cursorJSON
+	if err := json.Unmarshal(data[:n], &decoded); err != nil || decoded.PMID == "" {
+		return ErrInvalidCursor
+	}
+
+	*c = Cursor(decoded)
+	return nil
+}
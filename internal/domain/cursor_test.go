@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "relevance", Score: 0.1 + 0.2, PMID: "31452104"},
+		{Sort: "relevance", Score: 0, PMID: "1"},
+		{Sort: "year_desc", Year: 2021, PMID: "12"},
+		{Sort: "year_asc", Year: 0, PMID: "12"},
+	}
+
+	for _, cursor := range cursors {
+		text, err := cursor.MarshalText()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var decoded Cursor
+		if err := decoded.UnmarshalText(text); err != nil {
+			t.Fatalf("unexpected error decoding %q: %v", text, err)
+		}
+		// Scores must survive exactly for the keyset comparison to work
+		if decoded != cursor {
+			t.Errorf("expected %+v but got %+v", cursor, decoded)
+		}
+	}
+
+	// Cursors are JSON strings inside responses
+	data, err := json.Marshal(SearchResult{NextCursor: &cursors[2]})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var result SearchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.NextCursor == nil || *result.NextCursor != cursors[2] {
+		t.Errorf("expected %+v but got %+v", cursors[2], result.NextCursor)
+	}
+}
+
+func TestCursor_UnmarshalTextInvalid(t *testing.T) {
+	for _, text := range []string{"", "not base64!", "bm90IGpzb24", "e30"} {
+		var cursor Cursor
+		if err := cursor.UnmarshalText([]byte(text)); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q but got %v", text, err)
+		}
+	}
+}
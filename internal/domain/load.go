@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// MaxLoadFailures caps the failures kept in a LoadReport so that a badly
+// broken file cannot exhaust memory; the counts stay exact.
+const MaxLoadFailures = 100
+
+// LoadReport summarizes a data load. Every article record read is counted
+// exactly once as accepted, rejected or duplicate.
+type LoadReport struct {
+	Source     string    `json:"source"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	// Accepted counts valid records stored
+	Accepted int `json:"accepted"`
+	// Rejected counts records skipped because they were malformed, invalid
+	// or could not be stored
+	Rejected int `json:"rejected"`
+	// Duplicates counts valid records whose PMID was already stored, by an
+	// earlier record of the load or a previous load; they replace it
+	Duplicates int `json:"duplicates"`
+	// Deleted counts articles removed by DeleteCitation blocks
+	Deleted  int           `json:"deleted"`
+	Failures []LoadFailure `json:"failures"`
+	// FailuresTruncated reports that more than MaxLoadFailures records were
+	// rejected and only the first ones are listed
+	FailuresTruncated bool `json:"failures_truncated,omitempty"`
+	// Skipped reports that every source had already been loaded and none
+	// was read again
+	Skipped bool `json:"skipped,omitempty"`
+	// Sources breaks the counts down by file or object, in load order, when
+	// the load read more than one
+	Sources []SourceReport `json:"sources,omitempty"`
+}
+
+// SourceReport summarizes the load of one file or object
+type SourceReport struct {
+	Source     string `json:"source"`
+	Accepted   int    `json:"accepted"`
+	Rejected   int    `json:"rejected"`
+	Duplicates int    `json:"duplicates"`
+	Deleted    int    `json:"deleted"`
+	Skipped    bool   `json:"skipped,omitempty"`
+}
+
+// LoadRecord is the load history entry of a data source, kept so that a
+// source that has already been applied is not applied again
+type LoadRecord struct {
+	Source string
+	// Version identifies the revision of the source without reading it: the
+	// ETag of an S3 object or the size and modification time of a file
+	Version string
+	// Fingerprint identifies the content that was loaded, e.g. a checksum
+	Fingerprint string
+	LoadedAt    time.Time
+	Accepted    int
+	Rejected    int
+	Duplicates  int
+	Deleted     int
+}
+
+// LoadFailure describes a rejected record
+type LoadFailure struct {
+	// Source is the file or object the record was read from, when the load
+	// read more than one
+	Source string `json:"source,omitempty"`
+	// Line is the 1-based line of the source the record starts on
+	Line   int    `json:"line"`
+	PMID   string `json:"pmid,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// AddFailure counts a rejected record and records why it was rejected
+func (r *LoadReport) AddFailure(failure LoadFailure) {
+	r.Rejected++
+	if len(r.Failures) >= MaxLoadFailures {
+		r.FailuresTruncated = true
+		return
+	}
+	r.Failures = append(r.Failures, failure)
+}
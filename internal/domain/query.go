@@ -0,0 +1,67 @@
+package domain
+
+// QueryNode is a node in a parsed boolean search query. The service layer
+// parses the q parameter into a tree of these nodes and each repository
+// compiles the tree into its own query language.
+type QueryNode interface {
+	queryNode()
+}
+
+// QueryField restricts a query term to one field of an article
+type QueryField string
+
+// Supported query fields, named after their PubMed search tags. FieldAll is
+// the field of untagged terms: title and abstract, plus MeSH terms when the
+// search includes them.
+const (
+	FieldAll           QueryField = "all"
+	FieldTitleAbstract QueryField = "tiab"
+	FieldTitle         QueryField = "ti"
+	FieldAbstract      QueryField = "ab"
+	FieldAuthor        QueryField = "au"
+	FieldJournal       QueryField = "ta"
+	FieldMesh          QueryField = "mh"
+)
+
+// IsText reports whether the field is matched against the full-text index
+func (f QueryField) IsText() bool {
+	return f == FieldAll || f == FieldTitleAbstract || f == FieldTitle || f == FieldAbstract
+}
+
+// QueryTerm matches a word or phrase in a field. Full-text fields match whole
+// words (or word prefixes when Prefix is set); an author term matches part of
+// an author name, and journal and MeSH terms match a journal name or MeSH
+// term exactly, ignoring case.
+type QueryTerm struct {
+	Text   string
+	Field  QueryField
+	Prefix bool
+}
+
+// QueryYears matches articles published between From and To inclusive
+type QueryYears struct {
+	From int
+	To   int
+}
+
+// QueryAnd matches articles matched by every operand
+type QueryAnd struct {
+	Operands []QueryNode
+}
+
+// QueryOr matches articles matched by at least one operand
+type QueryOr struct {
+	Operands []QueryNode
+}
+
+// QueryNot matches articles matched by Include but not by Exclude
+type QueryNot struct {
+	Include QueryNode
+	Exclude QueryNode
+}
+
+func (*QueryTerm) queryNode()  {}
+func (*QueryYears) queryNode() {}
+func (*QueryAnd) queryNode()   {}
+func (*QueryOr) queryNode()    {}
+func (*QueryNot) queryNode()   {}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"pubmed-api/internal/citation"
+	"pubmed-api/internal/domain"
+	"pubmed-api/internal/service"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exportFormat describes how articles are serialized in a response
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) articleWriter
+}
+
+// articleWriter serializes a stream of articles
+type articleWriter interface {
+	// Write writes one article, possibly buffering it
+	Write(article *domain.Article) error
+	// Close writes anything still buffered and ends the stream
+	Close() error
+}
+
+// exportFormats maps the format parameter of /v1/articles/export to its
+// serializer
+var exportFormats = withCitationFormats(map[string]exportFormat{
+	"ndjson": {contentType: "application/x-ndjson", extension: "ndjson", newWriter: newNDJSONWriter},
+	"csv":    {contentType: "text/csv; charset=utf-8", extension: "csv", newWriter: newCSVWriter},
+	"tsv":    {contentType: "text/tab-separated-values; charset=utf-8", extension: "tsv", newWriter: newTSVWriter},
+	// The citation writer depends on the style parameter and is set per request
+	citationFormatName: {contentType: "text/plain; charset=utf-8", extension: "txt"},
+})
+
+// citationFormatName is the export format of formatted citations, one per line
+const citationFormatName = "citation"
+
+// articleFormats are the formats /v1/articles and /v1/articles/{pmid} respond
+// in. JSON, the default, is written as usual and has no serializer.
+var articleFormats = withCitationFormats(map[string]exportFormat{
+	"json": {contentType: "application/json"},
+})
+
+// formatAliases are other names accepted for formats in the format parameter
+var formatAliases = map[string]string{
+	"bib":  citation.BibTeX.Name,
+	"nbib": citation.MEDLINE.Name,
+}
+
+// withCitationFormats adds the citation formats to formats
+func withCitationFormats(formats map[string]exportFormat) map[string]exportFormat {
+	for _, f := range citation.Formats {
+		contentType := f.MediaType
+		if !strings.HasSuffix(contentType, "json") {
+			contentType += "; charset=utf-8"
+		}
+		formats[f.Name] = exportFormat{
+			contentType: contentType,
+			extension:   f.Extension,
+			newWriter:   func(w io.Writer) articleWriter { return f.NewWriter(w) },
+		}
+	}
+	return formats
+}
+
+// negotiateFormat returns the name of the format of formats a request asks
+// for: the format parameter when set, otherwise the media type the Accept
+// header prefers among those of formats. It returns "" when the request
+// asks for none of them, and an error for an unknown format parameter.
+func negotiateFormat(r *http.Request, formats map[string]exportFormat) (string, error) {
+	if name := strings.ToLower(r.URL.Query().Get("format")); name != "" {
+		if alias, ok := formatAliases[name]; ok {
+			name = alias
+		}
+		if _, ok := formats[name]; !ok {
+			return "", fmt.Errorf("unknown format %q", name)
+		}
+		return name, nil
+	}
+
+	byMediaType := make(map[string]string, len(formats))
+	for name, format := range formats {
+		mediaType, _, _ := mime.ParseMediaType(format.contentType)
+		byMediaType[mediaType] = name
+	}
+
+	type acceptedType struct {
+		mediaType string
+		q         float64
+	}
+	var accepted []acceptedType
+	for _, header := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if value, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(value, 64); err != nil {
+					continue
+				}
+			}
+			if q > 0 {
+				accepted = append(accepted, acceptedType{mediaType: mediaType, q: q})
+			}
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	for _, a := range accepted {
+		if name, ok := byMediaType[a.mediaType]; ok {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// ndjsonWriter writes one JSON article per line
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) articleWriter {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) Write(article *domain.Article) error {
+	return n.enc.Encode(article)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+// exportColumns is the header row of CSV and TSV exports. Authors and MeSH
+// terms are joined with "; ".
+var exportColumns = []string{"pmid", "title", "abstract", "authors", "journal", "pub_year", "mesh_terms", "doi"}
+
+// exportRow returns the fields of an article in exportColumns order
+func exportRow(article *domain.Article) []string {
+	return []string{
+		article.PMID,
+		article.Title,
+		article.Abstract,
+		strings.Join(article.Authors, "; "),
+		article.Journal,
+		strconv.Itoa(article.PubYear),
+		strings.Join(article.MeshTerms, "; "),
+		article.DOI,
+	}
+}
+
+// csvWriter writes RFC 4180 CSV with a header row. Rows are flushed as they
+// are written so that a streamed export is not held back by the CSV buffer.
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) articleWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.wroteHeader {
+		return nil
+	}
+	c.wroteHeader = true
+	return c.w.Write(exportColumns)
+}
+
+func (c *csvWriter) Write(article *domain.Article) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	if err := c.w.Write(exportRow(article)); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// tsvWriter writes tab-separated values with a header row. Fields are not
+// quoted, so tabs and line breaks inside them are replaced by spaces.
+type tsvWriter struct {
+	w           io.Writer
+	wroteHeader bool
+}
+
+// tsvReplacer removes the characters that would break a TSV row
+var tsvReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+func newTSVWriter(w io.Writer) articleWriter {
+	return &tsvWriter{w: w}
+}
+
+func (t *tsvWriter) writeRow(fields []string) error {
+	for i, field := range fields {
+		fields[i] = tsvReplacer.Replace(field)
+	}
+	_, err := io.WriteString(t.w, strings.Join(fields, "\t")+"\n")
+	return err
+}
+
+func (t *tsvWriter) writeHeader() error {
+	if t.wroteHeader {
+		return nil
+	}
+	t.wroteHeader = true
+	return t.writeRow(append([]string(nil), exportColumns...))
+}
+
+func (t *tsvWriter) Write(article *domain.Article) error {
+	if err := t.writeHeader(); err != nil {
+		return err
+	}
+	return t.writeRow(exportRow(article))
+}
+
+func (t *tsvWriter) Close() error {
+	return t.writeHeader()
+}
+
+// citationTextWriter writes one formatted citation per line
+type citationTextWriter struct {
+	w         io.Writer
+	formatter *service.CitationFormatter
+}
+
+func newCitationTextWriter(formatter *service.CitationFormatter) func(w io.Writer) articleWriter {
+	return func(w io.Writer) articleWriter {
+		return &citationTextWriter{w: w, formatter: formatter}
+	}
+}
+
+func (c *citationTextWriter) Write(article *domain.Article) error {
+	_, err := io.WriteString(c.w, c.formatter.Format(article)+"\n")
+	return err
+}
+
+func (c *citationTextWriter) Close() error {
+	return nil
+}
@@ -2,7 +2,10 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"pubmed-api/internal/domain"
 	"pubmed-api/internal/service"
 	"time"
 
@@ -41,20 +44,125 @@ func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 
 var startTime = time.Now()
 
-// GetArticles handles GET /v1/articles requests
+// GetArticles handles GET /v1/articles requests. The page of articles can be
+// rendered in a citation format instead of JSON, without the page metadata.
+// With the pmid parameter it looks up those articles instead of searching.
 func (h *Handler) GetArticles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	formatName, err := negotiateFormat(r, articleFormats)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if pmids, ok := service.ParsePMIDs(r.URL.Query()); ok {
+		h.writeBatch(w, r, formatName, pmids)
+		return
+	}
+
 	filters := service.ParseSearchFilters(r.URL.Query())
 
 	result, err := h.service.SearchArticles(r.Context(), filters)
+	if h.writeFilterError(w, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("failed to search articles", "error", err)
 		h.writeError(w, http.StatusInternalServerError, "failed to search articles")
 		return
 	}
 
+	if format := articleFormats[formatName]; format.newWriter != nil {
+		articles := make([]*domain.Article, len(result.Items))
+		for i, hit := range result.Items {
+			articles[i] = hit.Article
+		}
+		h.writeArticles(w, format, articles)
+		return
+	}
+
 	h.writeJSON(w, http.StatusOK, result)
 }
 
+// exportFlushInterval is the number of articles written between flushes of
+// an export response
+const exportFlushInterval = 100
+
+// ExportArticles handles GET /v1/articles/export requests. It streams every
+// article matching the search filters in the requested format, ignoring
+// pagination. Headers are written with the first article, so invalid filters
+// still get a 400; an error after that can only end the response early.
+func (h *Handler) ExportArticles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	formatName, err := negotiateFormat(r, exportFormats)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if formatName == "" {
+		formatName = "ndjson"
+	}
+	format := exportFormats[formatName]
+	if formatName == citationFormatName {
+		formatter, err := service.ParseCitationFormatter(r.URL.Query())
+		if h.writeFilterError(w, err) {
+			return
+		}
+		format.newWriter = newCitationTextWriter(formatter)
+	}
+
+	filters := service.ParseSearchFilters(r.URL.Query())
+
+	// An export can take longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.Warn("failed to clear export write deadline", "error", err)
+	}
+
+	out := format.newWriter(w)
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"articles.%s\"", format.extension))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	written := 0
+	err = h.service.ExportArticles(r.Context(), filters, func(hit *domain.SearchHit) error {
+		if !started {
+			start()
+		}
+		if err := out.Write(hit.Article); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushInterval == 0 {
+			return rc.Flush()
+		}
+		return nil
+	})
+	if !started {
+		if h.writeFilterError(w, err) {
+			return
+		}
+		if err != nil {
+			h.logger.Error("failed to export articles", "error", err)
+			h.writeError(w, http.StatusInternalServerError, "failed to export articles")
+			return
+		}
+		start()
+	}
+	if err != nil {
+		h.logger.Error("export ended early", "articles", written, "error", err)
+		return
+	}
+
+	if err := out.Close(); err != nil {
+		h.logger.Error("failed to finish export", "error", err)
+	}
+}
+
 // GetArticle handles GET /v1/articles/{pmid} requests
 func (h *Handler) GetArticle(w http.ResponseWriter, r *http.Request) {
 	pmid := chi.URLParam(r, "pmid")
@@ -63,6 +171,13 @@ func (h *Handler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Add("Vary", "Accept")
+	formatName, err := negotiateFormat(r, articleFormats)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	article, err := h.service.GetArticle(r.Context(), pmid)
 	if err != nil {
 		h.logger.Error("failed to get article", "pmid", pmid, "error", err)
@@ -70,12 +185,97 @@ func (h *Handler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := articleFormats[formatName]; format.newWriter != nil {
+		h.writeArticles(w, format, []*domain.Article{article})
+		return
+	}
+
 	h.writeJSON(w, http.StatusOK, article)
 }
 
+// maxBatchBodyBytes bounds the request body of a batch lookup
+const maxBatchBodyBytes = 1 << 20
+
+// batchGetRequest is the request body of POST /v1/articles:batchGet
+type batchGetRequest struct {
+	PMIDs []string `json:"pmids"`
+}
+
+// BatchGetArticles handles POST /v1/articles:batchGet requests
+func (h *Handler) BatchGetArticles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	formatName, err := negotiateFormat(r, articleFormats)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req batchGetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	h.writeBatch(w, r, formatName, req.PMIDs)
+}
+
+// writeBatch looks up pmids and writes the result, as JSON with the missing
+// PMIDs or as the found articles in a citation format
+func (h *Handler) writeBatch(w http.ResponseWriter, r *http.Request, formatName string, pmids []string) {
+	result, err := h.service.BatchGetArticles(r.Context(), pmids)
+	if h.writeFilterError(w, err) {
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get articles", "pmids", len(pmids), "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get articles")
+		return
+	}
+
+	if format := articleFormats[formatName]; format.newWriter != nil {
+		h.writeArticles(w, format, result.Articles)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// CiteArticle handles GET /v1/articles/{pmid}/cite requests
+func (h *Handler) CiteArticle(w http.ResponseWriter, r *http.Request) {
+	pmid := chi.URLParam(r, "pmid")
+	if pmid == "" {
+		h.writeError(w, http.StatusBadRequest, "pmid is required")
+		return
+	}
+
+	formatter, err := service.ParseCitationFormatter(r.URL.Query())
+	if h.writeFilterError(w, err) {
+		return
+	}
+
+	article, err := h.service.GetArticle(r.Context(), pmid)
+	if err != nil {
+		h.logger.Error("failed to get article", "pmid", pmid, "error", err)
+		h.writeError(w, http.StatusNotFound, "article not found")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, &domain.FormattedCitation{
+		PMID:     article.PMID,
+		Style:    formatter.Style(),
+		Citation: formatter.Format(article),
+	})
+}
+
 // GetStats handles GET /v1/stats requests
 func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.service.GetStats(r.Context())
+	filters := service.ParseSearchFilters(r.URL.Query())
+	topJournals := service.ParseTopJournals(r.URL.Query())
+
+	stats, err := h.service.GetStats(r.Context(), filters, topJournals)
+	if h.writeFilterError(w, err) {
+		return
+	}
 	if err != nil {
 		h.logger.Error("failed to get stats", "error", err)
 		h.writeError(w, http.StatusInternalServerError, "failed to get stats")
@@ -85,6 +285,40 @@ func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
 	h.writeJSON(w, http.StatusOK, stats)
 }
 
+// GetLoadReport handles GET /v1/admin/load-report requests
+func (h *Handler) GetLoadReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.service.GetLoadReport(r.Context())
+	if errors.Is(err, service.ErrNoLoadReport) {
+		h.writeError(w, http.StatusNotFound, "no load report available")
+		return
+	}
+	if err != nil {
+		h.logger.Error("failed to get load report", "error", err)
+		h.writeError(w, http.StatusInternalServerError, "failed to get load report")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, report)
+}
+
+// writeFilterError writes a 400 response for errors caused by invalid search
+// parameters and reports whether it did
+func (h *Handler) writeFilterError(w http.ResponseWriter, err error) bool {
+	var syntaxErr *service.QuerySyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		h.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":    syntaxErr.Error(),
+			"position": syntaxErr.Position,
+		})
+	case errors.Is(err, service.ErrInvalidFilter):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
 // writeJSON writes a JSON response
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -94,6 +328,23 @@ func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{})
 	}
 }
 
+// writeArticles writes a response of articles in a format other than JSON
+func (h *Handler) writeArticles(w http.ResponseWriter, format exportFormat, articles []*domain.Article) {
+	w.Header().Set("Content-Type", format.contentType)
+	w.WriteHeader(http.StatusOK)
+
+	out := format.newWriter(w)
+	for _, article := range articles {
+		if err := out.Write(article); err != nil {
+			h.logger.Error("failed to write articles", "error", err)
+			return
+		}
+	}
+	if err := out.Close(); err != nil {
+		h.logger.Error("failed to write articles", "error", err)
+	}
+}
+
 // writeError writes an error response
 func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"pubmed-api/internal/domain"
+	"pubmed-api/internal/service"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -17,8 +21,10 @@ import (
 
 // mockService is a mock implementation of ArticleServiceInterface
 type mockService struct {
-	articles map[string]*domain.Article
-	stats    *domain.Stats
+	articles     map[string]*domain.Article
+	stats        *domain.Stats
+	loadReport   *domain.LoadReport
+	lastStatsTop int
 }
 
 // Ensure mockService implements ArticleServiceInterface
@@ -51,27 +57,85 @@ func (m *mockService) GetArticle(ctx context.Context, pmid string) (*domain.Arti
 	return article, nil
 }
 
+func (m *mockService) BatchGetArticles(ctx context.Context, pmids []string) (*domain.BatchGetResult, error) {
+	if len(pmids) == 0 {
+		return nil, fmt.Errorf("%w: at least one pmid is required", service.ErrInvalidFilter)
+	}
+
+	result := &domain.BatchGetResult{Articles: []*domain.Article{}, Missing: []string{}}
+	for _, pmid := range pmids {
+		if article, ok := m.articles[pmid]; ok {
+			result.Articles = append(result.Articles, article)
+		} else {
+			result.Missing = append(result.Missing, pmid)
+		}
+	}
+	return result, nil
+}
+
 func (m *mockService) SearchArticles(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
-	var results []*domain.Article
+	if filters.Query != "" {
+		if _, err := service.ParseQuery(filters.Query); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []*domain.SearchHit
 	for _, article := range m.articles {
 		if filters.Query == "" || contains(article.Title, filters.Query) || contains(article.Abstract, filters.Query) {
-			results = append(results, article)
+			results = append(results, &domain.SearchHit{Article: article})
 		}
 	}
 
+	total := len(results)
 	return &domain.SearchResult{
 		Items:    results,
 		Page:     filters.Page,
 		PageSize: filters.PageSize,
-		Total:    len(results),
+		Total:    &total,
 		TookMs:   1,
 	}, nil
 }
 
-func (m *mockService) GetStats(ctx context.Context) (*domain.Stats, error) {
+func (m *mockService) ExportArticles(ctx context.Context, filters *domain.SearchFilters, fn func(hit *domain.SearchHit) error) error {
+	if filters.Query != "" {
+		if _, err := service.ParseQuery(filters.Query); err != nil {
+			return err
+		}
+	}
+
+	pmids := make([]string, 0, len(m.articles))
+	for pmid := range m.articles {
+		pmids = append(pmids, pmid)
+	}
+	sort.Strings(pmids)
+
+	for _, pmid := range pmids {
+		if err := fn(&domain.SearchHit{Article: m.articles[pmid]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockService) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	if filters.Query != "" {
+		if _, err := service.ParseQuery(filters.Query); err != nil {
+			return nil, err
+		}
+	}
+
+	m.lastStatsTop = topJournals
 	return m.stats, nil
 }
 
+func (m *mockService) GetLoadReport(ctx context.Context) (*domain.LoadReport, error) {
+	if m.loadReport == nil {
+		return nil, service.ErrNoLoadReport
+	}
+	return m.loadReport, nil
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0)
 }
@@ -166,7 +230,30 @@ func TestHandler_GetArticles(t *testing.T) {
 	var result domain.SearchResult
 	err := json.Unmarshal(w.Body.Bytes(), &result)
 	require.NoError(t, err)
-	assert.GreaterOrEqual(t, result.Total, 0)
+	require.NotNil(t, result.Total)
+	assert.GreaterOrEqual(t, *result.Total, 0)
+}
+
+func TestHandler_GetArticles_InvalidQuery(t *testing.T) {
+	logger := slog.Default()
+	mockSvc := newMockService()
+	handler := &Handler{
+		service: mockSvc,
+		logger:  logger,
+	}
+
+	req := httptest.NewRequest("GET", "/v1/articles?q=pain+AND+%28fever", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetArticles(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Contains(t, response["error"], "expected ) to close (")
+	assert.Equal(t, float64(16), response["position"])
 }
 
 func TestHandler_GetStats(t *testing.T) {
@@ -191,3 +278,352 @@ func TestHandler_GetStats(t *testing.T) {
 	assert.NotNil(t, stats.YearHistogram)
 }
 
+func TestHandler_GetStats_Filters(t *testing.T) {
+	logger := slog.Default()
+	mockSvc := newMockService()
+	handler := &Handler{
+		service: mockSvc,
+		logger:  logger,
+	}
+
+	req := httptest.NewRequest("GET", "/v1/stats?q=ibuprofen&top_n=20", nil)
+	w := httptest.NewRecorder()
+	handler.GetStats(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 20, mockSvc.lastStatsTop)
+
+	req = httptest.NewRequest("GET", "/v1/stats?q=%28ibuprofen", nil)
+	w = httptest.NewRecorder()
+	handler.GetStats(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_ExportArticles(t *testing.T) {
+	mockSvc := newMockService()
+	mockSvc.articles["23456789"] = &domain.Article{
+		PMID:      "23456789",
+		Title:     "Commas, \"quotes\"\tand tabs",
+		Abstract:  "Two\nlines",
+		Authors:   []string{"Author A", "Author B"},
+		Journal:   "Test Journal",
+		PubYear:   2021,
+		MeshTerms: []string{"Pain", "Fever"},
+		DOI:       "10.1000/test",
+	}
+	router := NewRouter(mockSvc, slog.Default())
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		filename    string
+		body        string
+	}{
+		{
+			name:        "ndjson by default",
+			query:       "",
+			contentType: "application/x-ndjson",
+			filename:    "articles.ndjson",
+			body: `{"pmid":"12345678","title":"Test Article","abstract":"Test abstract","authors":["Author A"],"journal":"Test Journal","pub_year":2020,"mesh_terms":null}` + "\n" +
+				`{"pmid":"23456789","title":"Commas, \"quotes\"\tand tabs","abstract":"Two\nlines","authors":["Author A","Author B"],"journal":"Test Journal","pub_year":2021,"mesh_terms":["Pain","Fever"],"doi":"10.1000/test"}` + "\n",
+		},
+		{
+			name:        "csv",
+			query:       "format=csv",
+			contentType: "text/csv; charset=utf-8",
+			filename:    "articles.csv",
+			body: "pmid,title,abstract,authors,journal,pub_year,mesh_terms,doi\n" +
+				"12345678,Test Article,Test abstract,Author A,Test Journal,2020,,\n" +
+				"23456789,\"Commas, \"\"quotes\"\"\tand tabs\",\"Two\nlines\",Author A; Author B,Test Journal,2021,Pain; Fever,10.1000/test\n",
+		},
+		{
+			name:        "tsv",
+			query:       "format=tsv",
+			contentType: "text/tab-separated-values; charset=utf-8",
+			filename:    "articles.tsv",
+			body: "pmid\ttitle\tabstract\tauthors\tjournal\tpub_year\tmesh_terms\tdoi\n" +
+				"12345678\tTest Article\tTest abstract\tAuthor A\tTest Journal\t2020\t\t\n" +
+				"23456789\tCommas, \"quotes\" and tabs\tTwo lines\tAuthor A; Author B\tTest Journal\t2021\tPain; Fever\t10.1000/test\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/articles/export?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Contains(t, w.Header().Get("Content-Disposition"), tt.filename)
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_ExportArticles_Errors(t *testing.T) {
+	router := NewRouter(newMockService(), slog.Default())
+
+	req := httptest.NewRequest("GET", "/v1/articles/export?format=xml", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown format")
+
+	req = httptest.NewRequest("GET", "/v1/articles/export?format=csv&q=%28pain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+}
+
+func TestHandler_CitationFormats(t *testing.T) {
+	router := NewRouter(newMockService(), slog.Default())
+
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		statusCode  int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "article as RIS by parameter",
+			target:      "/v1/articles/12345678?format=ris",
+			statusCode:  http.StatusOK,
+			contentType: "application/x-research-info-systems; charset=utf-8",
+			body:        "TY  - JOUR\n",
+		},
+		{
+			name:        "article as BibTeX by Accept",
+			target:      "/v1/articles/12345678",
+			accept:      "text/html, application/x-bibtex;q=0.9, */*;q=0.8",
+			statusCode:  http.StatusOK,
+			contentType: "application/x-bibtex; charset=utf-8",
+			body:        "@article{pmid12345678,\n",
+		},
+		{
+			name:        "parameter wins over Accept",
+			target:      "/v1/articles/12345678?format=nbib",
+			accept:      "application/x-bibtex",
+			statusCode:  http.StatusOK,
+			contentType: "application/nbib; charset=utf-8",
+			body:        "PMID- 12345678\n",
+		},
+		{
+			name:        "JSON preferred by quality",
+			target:      "/v1/articles/12345678",
+			accept:      "application/x-bibtex;q=0.5, application/json",
+			statusCode:  http.StatusOK,
+			contentType: "application/json",
+			body:        `{"pmid":"12345678"`,
+		},
+		{
+			name:        "search page as CSL-JSON",
+			target:      "/v1/articles",
+			accept:      "application/vnd.citationstyles.csl+json",
+			statusCode:  http.StatusOK,
+			contentType: "application/vnd.citationstyles.csl+json",
+			body:        "[\n{\"id\":\"12345678\",",
+		},
+		{
+			name:        "export as MEDLINE",
+			target:      "/v1/articles/export?format=medline",
+			statusCode:  http.StatusOK,
+			contentType: "application/nbib; charset=utf-8",
+			body:        "PMID- 12345678\n",
+		},
+		{
+			name:       "unknown format",
+			target:     "/v1/articles/12345678?format=endnote",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":"unknown format \"endnote\""}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			}
+			assert.True(t, strings.HasPrefix(w.Body.String(), tt.body), w.Body.String())
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestHandler_CiteArticle(t *testing.T) {
+	router := NewRouter(newMockService(), slog.Default())
+
+	tests := []struct {
+		name       string
+		target     string
+		statusCode int
+		citation   domain.FormattedCitation
+	}{
+		{
+			name:       "vancouver by default",
+			target:     "/v1/articles/12345678/cite",
+			statusCode: http.StatusOK,
+			citation:   domain.FormattedCitation{PMID: "12345678", Style: "vancouver", Citation: "Author A. Test Article. Test Journal. 2020. PMID: 12345678."},
+		},
+		{
+			name:       "apa",
+			target:     "/v1/articles/12345678/cite?style=apa",
+			statusCode: http.StatusOK,
+			citation:   domain.FormattedCitation{PMID: "12345678", Style: "apa", Citation: "Author, A. (2020). Test Article. Test Journal."},
+		},
+		{
+			name:       "unknown style",
+			target:     "/v1/articles/12345678/cite?style=harvard",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "not found",
+			target:     "/v1/articles/99999999/cite",
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.statusCode == http.StatusOK {
+				var citation domain.FormattedCitation
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &citation))
+				assert.Equal(t, tt.citation, citation)
+			}
+		})
+	}
+
+	// Bulk export writes one citation per line
+	req := httptest.NewRequest("GET", "/v1/articles/export?format=citation&style=ama", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Author A. Test Article. Test Journal. 2020.\n", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/v1/articles/export?format=citation&max_authors=none", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_BatchGetArticles(t *testing.T) {
+	mockSvc := newMockService()
+	mockSvc.articles["23456789"] = &domain.Article{PMID: "23456789", Title: "Second Article", Journal: "Test Journal", PubYear: 2021}
+	router := NewRouter(mockSvc, slog.Default())
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		statusCode int
+		articles   []string
+		missing    []string
+	}{
+		{
+			name:       "post",
+			method:     "POST",
+			target:     "/v1/articles:batchGet",
+			body:       `{"pmids": ["23456789", "404", "12345678"]}`,
+			statusCode: http.StatusOK,
+			articles:   []string{"23456789", "12345678"},
+			missing:    []string{"404"},
+		},
+		{
+			name:       "get",
+			method:     "GET",
+			target:     "/v1/articles?pmid=12345678,404&pmid=23456789",
+			statusCode: http.StatusOK,
+			articles:   []string{"12345678", "23456789"},
+			missing:    []string{"404"},
+		},
+		{
+			name:       "malformed body",
+			method:     "POST",
+			target:     "/v1/articles:batchGet",
+			body:       `{"pmids": "12345678"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "no pmids",
+			method:     "POST",
+			target:     "/v1/articles:batchGet",
+			body:       `{}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.statusCode, w.Code)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			var result domain.BatchGetResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			var pmids []string
+			for _, article := range result.Articles {
+				pmids = append(pmids, article.PMID)
+			}
+			assert.Equal(t, tt.articles, pmids)
+			assert.Equal(t, tt.missing, result.Missing)
+		})
+	}
+
+	// Citation formats hold the found articles
+	req := httptest.NewRequest("POST", "/v1/articles:batchGet?format=ris", strings.NewReader(`{"pmids": ["404", "23456789"]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(w.Body.String(), "TY  - JOUR"))
+	assert.Contains(t, w.Body.String(), "AN  - 23456789")
+}
+
+func TestHandler_GetLoadReport(t *testing.T) {
+	logger := slog.Default()
+	mockSvc := newMockService()
+	handler := &Handler{
+		service: mockSvc,
+		logger:  logger,
+	}
+
+	req := httptest.NewRequest("GET", "/v1/admin/load-report", nil)
+	w := httptest.NewRecorder()
+	handler.GetLoadReport(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockSvc.loadReport = &domain.LoadReport{Source: "embedded", Accepted: 2}
+	mockSvc.loadReport.AddFailure(domain.LoadFailure{Line: 3, Reason: "title is empty"})
+
+	w = httptest.NewRecorder()
+	handler.GetLoadReport(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report domain.LoadReport
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Equal(t, []domain.LoadFailure{{Line: 3, Reason: "title is empty"}}, report.Failures)
+}
@@ -28,7 +28,6 @@ func NewRouter(service ArticleServiceInterface, logger *slog.Logger) *chi.Mux {
 		})
 	})
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
 
 	// Timing middleware
 	r.Use(func(next http.Handler) http.Handler {
@@ -49,14 +48,25 @@ func NewRouter(service ArticleServiceInterface, logger *slog.Logger) *chi.Mux {
 	handler := NewHandler(service, logger)
 
 	// Routes
-	r.Get("/healthz", handler.Healthz)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(30 * time.Second))
 
-	r.Route("/v1", func(r chi.Router) {
-		r.Get("/articles", handler.GetArticles)
-		r.Get("/articles/{pmid}", handler.GetArticle)
-		r.Get("/stats", handler.GetStats)
+		r.Get("/healthz", handler.Healthz)
+
+		r.Route("/v1", func(r chi.Router) {
+			r.Get("/articles", handler.GetArticles)
+			r.Post("/articles:batchGet", handler.BatchGetArticles)
+			r.Get("/articles/{pmid}", handler.GetArticle)
+			r.Get("/articles/{pmid}/cite", handler.CiteArticle)
+			r.Get("/stats", handler.GetStats)
+			r.Get("/admin/load-report", handler.GetLoadReport)
+		})
 	})
 
+	// Exports stream for as long as there are articles to send, so they are
+	// not subject to the request timeout; they end when the client goes away
+	r.Get("/v1/articles/export", handler.ExportArticles)
+
 	return r
 }
 
@@ -10,8 +10,11 @@ import (
 // This allows for easier testing with mocks
 type ArticleServiceInterface interface {
 	GetArticle(ctx context.Context, pmid string) (*domain.Article, error)
+	BatchGetArticles(ctx context.Context, pmids []string) (*domain.BatchGetResult, error)
 	SearchArticles(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error)
-	GetStats(ctx context.Context) (*domain.Stats, error)
+	ExportArticles(ctx context.Context, filters *domain.SearchFilters, fn func(hit *domain.SearchHit) error) error
+	GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error)
+	GetLoadReport(ctx context.Context) (*domain.LoadReport, error)
 }
 
 // Ensure ArticleService implements the interface
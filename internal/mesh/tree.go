@@ -0,0 +1,199 @@
+// Package mesh reads the MeSH descriptor hierarchy used to explode MeSH
+// filters into their narrower terms.
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Tree is the MeSH descriptor hierarchy. Each descriptor has one or more
+// tree numbers such as D02.455.426.559.389.657.410; a descriptor is narrower
+// than another when one of its tree numbers extends one of the other's.
+type Tree struct {
+	// treeNumbers holds every tree number, sorted so that the descendants of
+	// a tree number form a contiguous run after it
+	treeNumbers []string
+	// names maps tree numbers to descriptor names
+	names map[string]string
+	// numbers maps lowercased descriptor names to their tree numbers
+	numbers map[string][]string
+}
+
+func newTree() *Tree {
+	return &Tree{
+		names:   make(map[string]string),
+		numbers: make(map[string][]string),
+	}
+}
+
+func (t *Tree) add(name, treeNumber string) {
+	name = strings.TrimSpace(name)
+	treeNumber = strings.TrimSpace(treeNumber)
+	if name == "" || treeNumber == "" {
+		return
+	}
+	if _, ok := t.names[treeNumber]; ok {
+		return
+	}
+
+	t.names[treeNumber] = name
+	key := strings.ToLower(name)
+	t.numbers[key] = append(t.numbers[key], treeNumber)
+	t.treeNumbers = append(t.treeNumbers, treeNumber)
+}
+
+// Len returns the number of descriptors in the tree
+func (t *Tree) Len() int {
+	return len(t.numbers)
+}
+
+// Descendants returns the names of all descriptors narrower than term,
+// sorted and without term itself. Terms are matched ignoring case; an
+// unknown term has no descendants.
+func (t *Tree) Descendants(term string) []string {
+	key := strings.ToLower(strings.TrimSpace(term))
+
+	seen := map[string]bool{}
+	var result []string
+	for _, treeNumber := range t.numbers[key] {
+		prefix := treeNumber + "."
+		for i := sort.SearchStrings(t.treeNumbers, prefix); i < len(t.treeNumbers) && strings.HasPrefix(t.treeNumbers[i], prefix); i++ {
+			name := t.names[t.treeNumbers[i]]
+			if lower := strings.ToLower(name); lower != key && !seen[lower] {
+				seen[lower] = true
+				result = append(result, name)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// ParseTree reads a MeSH hierarchy in one of two formats, detected from
+// the first non-blank character:
+//
+//   - the NLM descriptor XML (desc20XX.xml), read as a stream
+//   - a text file with one "name<TAB>tree number" pair per line; the NLM
+//     mtrees "name;tree number" format is accepted too. Blank lines and lines
+//     starting with # are ignored.
+func ParseTree(r io.Reader) (*Tree, error) {
+	br := bufio.NewReader(r)
+
+	// Skip a byte order mark and leading blank space, counting the lines
+	// skipped so that errors report the right line
+	var first byte
+	skipped := 0
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, errors.New("MeSH tree file is empty")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MeSH tree: %w", err)
+		}
+		if bom, _ := br.Peek(3); bytes.Equal(bom, utf8BOM) {
+			br.Discard(3)
+			continue
+		}
+		if b[0] != ' ' && b[0] != '\t' && b[0] != '\r' && b[0] != '\n' {
+			first = b[0]
+			break
+		}
+		if b[0] == '\n' {
+			skipped++
+		}
+		br.Discard(1)
+	}
+
+	var tree *Tree
+	var err error
+	if first == '<' {
+		tree, err = parseDescriptorXML(br)
+	} else {
+		tree, err = parseTreeText(br, skipped)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(tree.treeNumbers)
+	return tree, nil
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// descriptorRecord is the part of a DescriptorRecord the tree needs
+type descriptorRecord struct {
+	Name        string   `xml:"DescriptorName>String"`
+	TreeNumbers []string `xml:"TreeNumberList>TreeNumber"`
+}
+
+func parseDescriptorXML(r io.Reader) (*Tree, error) {
+	tree := newTree()
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse MeSH descriptor XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "DescriptorRecord" {
+			continue
+		}
+
+		var record descriptorRecord
+		if err := decoder.DecodeElement(&record, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse MeSH descriptor XML: %w", err)
+		}
+		for _, treeNumber := range record.TreeNumbers {
+			tree.add(record.Name, treeNumber)
+		}
+	}
+
+	return tree, nil
+}
+
+// parseTreeText reads the text format from r, which starts after the given
+// number of lines
+func parseTreeText(r io.Reader, lineNum int) (*Tree, error) {
+	tree := newTree()
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, treeNumber, ok := strings.Cut(line, "\t")
+		if !ok {
+			// Descriptor names may contain semicolons, tree numbers never do
+			i := strings.LastIndex(line, ";")
+			if i < 0 {
+				return nil, fmt.Errorf("MeSH tree line %d: expected a name and a tree number", lineNum)
+			}
+			name, treeNumber = line[:i], line[i+1:]
+		}
+		tree.add(name, treeNumber)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read MeSH tree: %w", err)
+	}
+
+	return tree, nil
+}
@@ -0,0 +1,104 @@
+package mesh
+
+import (
+	"strings"
+	"testing"
+)
+
+const testTreeTSV = `# name	tree number
+Anti-Inflammatory Agents	D27.505.954.158
+Anti-Inflammatory Agents, Non-Steroidal	D27.505.954.158.200
+Ibuprofen	D27.505.954.158.200.400
+Ibuprofen	D02.455.426.559.389.657.410
+Aspirin	D27.505.954.158.200.100
+Analgesics	D27.505.954.427
+`
+
+func TestParseTree_TSV(t *testing.T) {
+	tree, err := ParseTree(strings.NewReader(testTreeTSV))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tree.Len() != 5 {
+		t.Errorf("expected 5 descriptors but got %d", tree.Len())
+	}
+
+	tests := []struct {
+		term string
+		want string
+	}{
+		{term: "Anti-Inflammatory Agents", want: "Anti-Inflammatory Agents, Non-Steroidal|Aspirin|Ibuprofen"},
+		{term: "anti-inflammatory agents, non-steroidal", want: "Aspirin|Ibuprofen"},
+		{term: "Ibuprofen", want: ""},
+		{term: "Unknown", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			if got := strings.Join(tree.Descendants(tt.term), "|"); got != tt.want {
+				t.Errorf("expected %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTree_Mtrees(t *testing.T) {
+	tree, err := ParseTree(strings.NewReader("Pain;C23.888.592.612\nPain, Postoperative;C23.888.592.612.833\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(tree.Descendants("Pain"), "|"); got != "Pain, Postoperative" {
+		t.Errorf("expected Pain, Postoperative but got %q", got)
+	}
+}
+
+func TestParseTree_DescriptorXML(t *testing.T) {
+	const xmlData = `<?xml version="1.0"?>
+<DescriptorRecordSet LanguageCode="eng">
+  <DescriptorRecord DescriptorClass="1">
+    <DescriptorUI>D000893</DescriptorUI>
+    <DescriptorName><String>Anti-Inflammatory Agents</String></DescriptorName>
+    <TreeNumberList><TreeNumber>D27.505.954.158</TreeNumber></TreeNumberList>
+    <ConceptList>
+      <Concept PreferredConceptYN="Y">
+        <ConceptName><String>Anti-Inflammatory Agents</String></ConceptName>
+      </Concept>
+    </ConceptList>
+  </DescriptorRecord>
+  <DescriptorRecord DescriptorClass="1">
+    <DescriptorUI>D007052</DescriptorUI>
+    <DescriptorName><String>Ibuprofen</String></DescriptorName>
+    <TreeNumberList>
+      <TreeNumber>D02.455.426.559.389.657.410</TreeNumber>
+      <TreeNumber>D27.505.954.158.200.400</TreeNumber>
+    </TreeNumberList>
+  </DescriptorRecord>
+</DescriptorRecordSet>
+`
+
+	tree, err := ParseTree(strings.NewReader(xmlData))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(tree.Descendants("Anti-Inflammatory Agents"), "|"); got != "Ibuprofen" {
+		t.Errorf("expected Ibuprofen but got %q", got)
+	}
+}
+
+func TestParseTree_Invalid(t *testing.T) {
+	for _, data := range []string{"", "  \n", "Pain without a tree number\n", "<DescriptorRecordSet><DescriptorRecord>"} {
+		if _, err := ParseTree(strings.NewReader(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseTree_ErrorLine(t *testing.T) {
+	_, err := ParseTree(strings.NewReader("\n\n# comment\nPain;C23.888.592.612\nPain without a tree number\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 5") {
+		t.Errorf("expected an error on line 5 but got %v", err)
+	}
+}
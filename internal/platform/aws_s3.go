@@ -5,53 +5,114 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
-// LoadFromS3 loads data from an S3 bucket
-func LoadFromS3(ctx context.Context, s3URL string, logger *slog.Logger) ([]byte, error) {
-	// Parse S3 URL: s3://bucket/key
+// parseS3URL splits an s3://bucket/key URL. A key that is empty or ends with
+// a slash is a prefix.
+func parseS3URL(s3URL string) (bucket, key string, err error) {
 	if !strings.HasPrefix(s3URL, "s3://") {
-		return nil, fmt.Errorf("invalid S3 URL format: %s", s3URL)
+		return "", "", fmt.Errorf("invalid S3 URL format: %s", s3URL)
 	}
 
-	path := strings.TrimPrefix(s3URL, "s3://")
-	parts := strings.SplitN(path, "/", 2)
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid S3 URL format: %s", s3URL)
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(s3URL, "s3://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URL format: %s", s3URL)
 	}
+	return bucket, key, nil
+}
 
-	bucket := parts[0]
-	key := parts[1]
-
-	logger.Info("loading data from S3", "bucket", bucket, "key", key)
-
+// NewS3Client creates an S3 client from the default AWS configuration. A
+// non-empty endpoint replaces the AWS one, e.g. to use MinIO, and switches
+// to path-style addressing.
+func NewS3Client(ctx context.Context, endpoint string) (*s3.Client, error) {
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
 
-	result, err := client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
+// s3Sources returns the objects an S3 URL refers to. A URL ending with a
+// slash, such as s3://bucket/pubmed/, is a prefix: the data files under it
+// are listed and loaded in key order. Objects are downloaded when the
+// loader gets to them, so at most LOAD_WORKERS are read at a time.
+func s3Sources(ctx context.Context, client *s3.Client, s3URL string, logger *slog.Logger) ([]dataSource, error) {
+	bucket, key, err := parseS3URL(s3URL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object from S3: %w", err)
+		return nil, err
 	}
-	defer result.Body.Close()
 
-	data, err := io.ReadAll(result.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read S3 object: %w", err)
+	if key != "" && !strings.HasSuffix(key, "/") {
+		logger.Info("loading data from S3", "bucket", bucket, "key", key)
+
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get object from S3: %w", err)
+		}
+		logger.Info("found S3 object", "size", aws.ToInt64(head.ContentLength))
+		return []dataSource{s3Source(client, bucket, key, aws.ToString(head.ETag))}, nil
+	}
+
+	logger.Info("listing data in S3", "bucket", bucket, "prefix", key)
+
+	var objects []types.Object
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects in S3: %w", err)
+		}
+		for _, object := range page.Contents {
+			if isDataFile(aws.ToString(object.Key)) {
+				objects = append(objects, object)
+			}
+		}
 	}
+	sort.Slice(objects, func(i, j int) bool {
+		return aws.ToString(objects[i].Key) < aws.ToString(objects[j].Key)
+	})
 
-	logger.Info("loaded data from S3", "size", len(data))
-	return data, nil
+	logger.Info("listed S3 objects", "count", len(objects))
+
+	sources := make([]dataSource, len(objects))
+	for i, object := range objects {
+		sources[i] = s3Source(client, bucket, aws.ToString(object.Key), aws.ToString(object.ETag))
+	}
+	return sources, nil
 }
 
+func s3Source(client *s3.Client, bucket, key, etag string) dataSource {
+	return dataSource{
+		name:    "s3://" + bucket + "/" + key,
+		version: etag,
+		open: func(ctx context.Context) (io.ReadCloser, error) {
+			result, err := client.GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(key),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get object from S3: %w", err)
+			}
+			return result.Body, nil
+		},
+	}
+}
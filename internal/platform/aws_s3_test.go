@@ -0,0 +1,236 @@
+package platform
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"pubmed-api/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves the objects of one bucket with path-style addressing,
+// listing at most pageSize keys per ListObjectsV2 page
+type fakeS3 struct {
+	bucket   string
+	objects  map[string][]byte
+	pageSize int
+
+	mu   sync.Mutex
+	gets []string
+}
+
+type fakeS3ListResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []fakeS3Object `xml:"Contents"`
+}
+
+type fakeS3Object struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+	Size int    `xml:"Size"`
+}
+
+func fakeETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	if key == "" && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r)
+		return
+	}
+
+	data, ok := f.objects[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodGet {
+		f.mu.Lock()
+		f.gets = append(f.gets, key)
+		f.mu.Unlock()
+	}
+	w.Header().Set("ETag", fakeETag(data))
+	w.Write(data)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	after := r.URL.Query().Get("continuation-token")
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := fakeS3ListResult{Name: f.bucket, Prefix: prefix}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	result.KeyCount = len(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, fakeS3Object{Key: key, ETag: fakeETag(f.objects[key]), Size: len(f.objects[key])})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func setFakeAWSCredentials(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func TestParseS3URL(t *testing.T) {
+	bucket, key, err := parseS3URL("s3://data/pubmed/baseline.jsonl")
+	require.NoError(t, err)
+	assert.Equal(t, "data", bucket)
+	assert.Equal(t, "pubmed/baseline.jsonl", key)
+
+	bucket, key, err = parseS3URL("s3://data")
+	require.NoError(t, err)
+	assert.Equal(t, "data", bucket)
+	assert.Empty(t, key)
+
+	_, _, err = parseS3URL("https://data/pubmed")
+	assert.Error(t, err)
+	_, _, err = parseS3URL("s3:///pubmed")
+	assert.Error(t, err)
+}
+
+func TestLoadArticles_S3Prefix(t *testing.T) {
+	setFakeAWSCredentials(t)
+
+	s3 := &fakeS3{bucket: "data", pageSize: 2, objects: map[string][]byte{
+		"pubmed/03.jsonl.gz": gzipData(t, []byte(`{"pmid":"3","title":"Three"}`+"\n")),
+		"pubmed/01.jsonl":    []byte(`{"pmid":"1","title":"One"}` + "\n"),
+		"pubmed/02.jsonl":    []byte(`{"pmid":"1","title":"One, revised"}` + "\n" + `{"pmid":"2","title":"Two"}` + "\n"),
+		"pubmed/04.jsonl":    []byte(`{"pmid":"4","title":"Four"}` + "\n"),
+		"pubmed/notes.md":    []byte("not data"),
+		"other/05.jsonl":     []byte(`{"pmid":"5","title":"Five"}` + "\n"),
+	}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataS3URL: "s3://data/pubmed/", DataS3Endpoint: server.URL, LoadWorkers: 2}
+
+	report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+
+	assert.Equal(t, "s3://data/pubmed/", report.Source)
+	assert.Equal(t, 4, report.Accepted)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, "One, revised", repo.stored["1"].Title)
+	assert.Equal(t, []string{"1", "1", "2", "3", "4"}, repo.pmids())
+
+	var sources []string
+	for _, source := range report.Sources {
+		sources = append(sources, source.Source)
+	}
+	assert.Equal(t, []string{
+		"s3://data/pubmed/01.jsonl",
+		"s3://data/pubmed/02.jsonl",
+		"s3://data/pubmed/03.jsonl.gz",
+		"s3://data/pubmed/04.jsonl",
+	}, sources)
+	assert.Len(t, s3.gets, 4)
+}
+
+func TestLoadArticles_S3Object(t *testing.T) {
+	setFakeAWSCredentials(t)
+
+	s3 := &fakeS3{bucket: "data", pageSize: 2, objects: map[string][]byte{
+		"pubmed/01.jsonl": []byte(`{"pmid":"1","title":"One"}` + "\n"),
+	}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataS3URL: "s3://data/pubmed/01.jsonl", DataS3Endpoint: server.URL}
+
+	report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, []string{"1"}, repo.pmids())
+}
+
+func TestLoadArticles_S3FallsBack(t *testing.T) {
+	setFakeAWSCredentials(t)
+
+	server := httptest.NewServer(&fakeS3{bucket: "data", pageSize: 2, objects: map[string][]byte{}})
+	defer server.Close()
+
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataS3URL: "s3://data/missing.jsonl", DataS3Endpoint: server.URL, DataPath: "../pubmed/testdata/medline.nbib"}
+
+	report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "../pubmed/testdata/medline.nbib", report.Source)
+	assert.Equal(t, 3, report.Accepted)
+}
+
+func TestLoadArticles_S3SkipsUnchangedObjects(t *testing.T) {
+	setFakeAWSCredentials(t)
+
+	s3 := &fakeS3{bucket: "data", pageSize: 10, objects: map[string][]byte{
+		"pubmed/01.jsonl": []byte(`{"pmid":"1","title":"One"}` + "\n"),
+		"pubmed/02.jsonl": []byte(`{"pmid":"2","title":"Two"}` + "\n"),
+	}}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataS3URL: "s3://data/pubmed/", DataS3Endpoint: server.URL}
+
+	_, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.Equal(t, fakeETag(s3.objects["pubmed/01.jsonl"]), repo.history["s3://data/pubmed/01.jsonl"].Version)
+
+	// Only the object whose ETag changed is downloaded again, once
+	s3.objects["pubmed/02.jsonl"] = []byte(`{"pmid":"2","title":"Two, revised"}` + "\n")
+	s3.gets = nil
+
+	spools := t.TempDir()
+	t.Setenv("TMPDIR", spools)
+	report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pubmed/02.jsonl"}, s3.gets)
+	entries, err := os.ReadDir(spools)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the spooled copy is removed")
+	assert.True(t, report.Sources[0].Skipped)
+	assert.Equal(t, "Two, revised", repo.stored["2"].Title)
+}
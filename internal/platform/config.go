@@ -3,17 +3,50 @@ package platform
 import (
 	"fmt"
 	"os"
+	"runtime"
+	"strconv"
+	"time"
 )
 
 // Config holds application configuration
 type Config struct {
-	Port       string
-	DataPath   string
-	DataS3URL  string
-	LogLevel   string
-	DBPath     string
+	Port      string
+	DataPath  string
+	DataS3URL string
+	LogLevel  string
+	// DBDriver selects the repository backend: DBDriverSQLite,
+	// DBDriverPostgres or DBDriverMemory
+	DBDriver string
+	DBPath   string
+	// DBURL is the PostgreSQL connection URL used by DBDriverPostgres
+	DBURL string
+	// DBAutoMigrate applies pending schema migrations on startup; when false
+	// they must be applied with the migrate subcommand
+	DBAutoMigrate bool
+	// DataS3Endpoint optionally replaces the AWS S3 endpoint, e.g. with a
+	// MinIO server
+	DataS3Endpoint string
+	// MeshTreePath optionally points at a MeSH hierarchy used by mesh_explode
+	MeshTreePath string
+	// LoadBatchSize is the number of articles inserted per transaction
+	LoadBatchSize int
+	// LoadStrict fails the load on the first invalid record instead of
+	// skipping it
+	LoadStrict bool
+	// LoadTimeout bounds the initial data load
+	LoadTimeout time.Duration
+	// LoadWorkers is the number of data files parsed in parallel
+	LoadWorkers int
 }
 
+// Supported DBDriver values
+const (
+	DBDriverSQLite   = "sqlite"
+	DBDriverPostgres = "postgres"
+	// DBDriverMemory keeps articles in process memory and needs no CGO
+	DBDriverMemory = "memory"
+)
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	port := os.Getenv("PORT")
@@ -36,6 +69,66 @@ func LoadConfig() (*Config, error) {
 		dbPath = ":memory:" // Use in-memory DB by default, can be changed to file
 	}
 
+	dbDriver := os.Getenv("DB_DRIVER")
+	if dbDriver == "" {
+		dbDriver = DBDriverSQLite
+	}
+	dbURL := os.Getenv("DB_URL")
+	switch dbDriver {
+	case DBDriverSQLite, DBDriverMemory:
+	case DBDriverPostgres:
+		if dbURL == "" {
+			return nil, fmt.Errorf("DB_URL is required when DB_DRIVER is %s", DBDriverPostgres)
+		}
+	default:
+		return nil, fmt.Errorf("invalid DB_DRIVER: %s", dbDriver)
+	}
+
+	dbAutoMigrate := true
+	if v := os.Getenv("DB_AUTO_MIGRATE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_AUTO_MIGRATE: %s", v)
+		}
+		dbAutoMigrate = b
+	}
+
+	loadBatchSize := DefaultLoadBatchSize
+	if v := os.Getenv("LOAD_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid LOAD_BATCH_SIZE: %s", v)
+		}
+		loadBatchSize = n
+	}
+
+	loadStrict := false
+	if v := os.Getenv("LOAD_STRICT"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOAD_STRICT: %s", v)
+		}
+		loadStrict = b
+	}
+
+	loadTimeout := 30 * time.Second
+	if v := os.Getenv("LOAD_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid LOAD_TIMEOUT: %s", v)
+		}
+		loadTimeout = d
+	}
+
+	loadWorkers := runtime.NumCPU()
+	if v := os.Getenv("LOAD_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid LOAD_WORKERS: %s", v)
+		}
+		loadWorkers = n
+	}
+
 	// Validate log level
 	validLevels := map[string]bool{
 		"debug": true,
@@ -48,11 +141,20 @@ func LoadConfig() (*Config, error) {
 	}
 
 	return &Config{
-		Port:      port,
-		DataPath:  dataPath,
-		DataS3URL: os.Getenv("DATA_S3_URL"),
-		LogLevel:  logLevel,
-		DBPath:    dbPath,
+		Port:           port,
+		DataPath:       dataPath,
+		DataS3URL:      os.Getenv("DATA_S3_URL"),
+		DataS3Endpoint: os.Getenv("DATA_S3_ENDPOINT"),
+		LogLevel:       logLevel,
+		DBDriver:       dbDriver,
+		DBPath:         dbPath,
+		DBURL:          dbURL,
+		DBAutoMigrate:  dbAutoMigrate,
+		MeshTreePath:   os.Getenv("MESH_TREE_PATH"),
+		LoadBatchSize:  loadBatchSize,
+		LoadStrict:     loadStrict,
+		LoadTimeout:    loadTimeout,
+		LoadWorkers:    loadWorkers,
 	}, nil
 }
 
@@ -71,4 +173,3 @@ func GetLogLevel(level string) int {
 		return 0
 	}
 }
-
@@ -2,103 +2,298 @@ package platform
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"pubmed-api/internal/domain"
 	"pubmed-api/internal/repo"
-	"strings"
+	"time"
 )
 
-// LoadArticles loads articles from various sources (S3, local file, or embedded)
-func LoadArticles(ctx context.Context, repo repo.ArticleRepository, cfg *Config, logger *slog.Logger) error {
-	var data []byte
-	var err error
-	var source string
-
-	// Priority 1: S3
-	if cfg.DataS3URL != "" {
-		data, err = LoadFromS3(ctx, cfg.DataS3URL, logger)
-		if err != nil {
-			logger.Warn("failed to load from S3, falling back", "error", err)
-		} else {
-			source = "S3"
-		}
+// DefaultLoadBatchSize is the number of articles inserted per transaction
+const DefaultLoadBatchSize = 1000
+
+// ArticleWriter is implemented by repositories that can be loaded with data
+type ArticleWriter interface {
+	// InsertArticles stores articles, replacing stored articles with the
+	// same PMID, and returns how many it replaced
+	InsertArticles(ctx context.Context, articles []*domain.Article) (int, error)
+	// DeleteArticles deletes articles by PMID and returns how many existed
+	DeleteArticles(ctx context.Context, pmids []string) (int, error)
+}
+
+// LoadHistory is implemented by repositories that remember which data
+// sources have been loaded into them. loadSources uses it to skip sources
+// already applied, so that an old update file is not replayed over newer
+// revisions.
+type LoadHistory interface {
+	FindLoad(ctx context.Context, source string) (*domain.LoadRecord, error)
+	RecordLoad(ctx context.Context, record *domain.LoadRecord) error
+}
+
+// RecordSink receives the records read from a data source. Loader is the
+// sink that stores them; the readers of each format only depend on this
+// interface so that sources can be parsed concurrently.
+type RecordSink interface {
+	// Add receives an article read from the given source line
+	Add(ctx context.Context, line int, article *domain.Article) error
+	// Delete receives the PMIDs of a deletion block of an update file
+	Delete(ctx context.Context, line int, pmids []string) error
+	// Reject receives a record that could not be read
+	Reject(line int, pmid, reason string) error
+}
+
+// LoadArticles loads articles from various sources (S3, local files, or
+// embedded) and returns a report of what was loaded
+func LoadArticles(ctx context.Context, repo repo.ArticleRepository, cfg *Config, logger *slog.Logger) (*domain.LoadReport, error) {
+	writer, ok := repo.(ArticleWriter)
+	if !ok {
+		return nil, fmt.Errorf("repository does not support loading articles")
 	}
 
-	// Priority 2: Local file
-	if data == nil {
-		if _, err := os.Stat(cfg.DataPath); err == nil {
-			data, err = os.ReadFile(cfg.DataPath)
-			if err != nil {
-				logger.Warn("failed to load from local file, falling back", "error", err)
-			} else {
-				source = "local file"
-			}
-		}
+	source, sources := openDataSources(ctx, cfg, logger)
+
+	history, _ := repo.(LoadHistory)
+
+	logger.Info("loading articles", "source", source, "files", len(sources))
+
+	loader := NewLoader(writer, cfg, logger)
+	loader.Report().Source = source
+
+	if err := loadSources(ctx, sources, loader, history, cfg.LoadWorkers, logger); err != nil {
+		return nil, err
+	}
+	if err := loader.Close(ctx); err != nil {
+		return nil, err
 	}
 
-	// Priority 3: Embedded fallback
-	if data == nil {
-		data = embeddedData
-		source = "embedded"
-		logger.Info("using embedded fallback data")
+	report := loader.Report()
+	logger.Info("articles loaded successfully",
+		"accepted", report.Accepted,
+		"rejected", report.Rejected,
+		"duplicates", report.Duplicates,
+		"deleted", report.Deleted,
+	)
+
+	return report, nil
+}
+
+// Loader validates articles and inserts them in batches. In the default
+// lenient mode invalid records are skipped and recorded in the load report;
+// in strict mode the first invalid record fails the load.
+type Loader struct {
+	writer    ArticleWriter
+	batchSize int
+	strict    bool
+	logger    *slog.Logger
+
+	batch []*domain.Article
+	// queued describes the articles in batch, in the same order
+	queued []queuedRecord
+	report *domain.LoadReport
+
+	// source is the source being read, set by BeginSource, and sourceStart
+	// the report counts when it began
+	source      string
+	sourceStart domain.SourceReport
+}
+
+// NewLoader creates a loader using the batch size and strictness in cfg
+func NewLoader(writer ArticleWriter, cfg *Config, logger *slog.Logger) *Loader {
+	batchSize := cfg.LoadBatchSize
+	if batchSize < 1 {
+		batchSize = DefaultLoadBatchSize
 	}
 
-	if data == nil {
-		return fmt.Errorf("no data source available")
+	return &Loader{
+		writer:    writer,
+		batchSize: batchSize,
+		strict:    cfg.LoadStrict,
+		logger:    logger,
+		batch:     make([]*domain.Article, 0, batchSize),
+		queued:    make([]queuedRecord, 0, batchSize),
+		report:    &domain.LoadReport{StartedAt: time.Now().UTC(), Failures: []domain.LoadFailure{}},
 	}
+}
 
-	logger.Info("loading articles", "source", source)
+// queuedRecord is where a queued article came from
+type queuedRecord struct {
+	line int
+}
+
+// Report returns the report of the load so far
+func (l *Loader) Report() *domain.LoadReport {
+	return l.report
+}
+
+// Add validates an article read from the given source line and queues it
+// for insertion. It is counted as accepted or duplicate once stored.
+func (l *Loader) Add(ctx context.Context, line int, article *domain.Article) error {
+	if err := article.Validate(); err != nil {
+		return l.Reject(line, article.PMID, err.Error())
+	}
 
-	articles, err := parseJSONL(data)
+	l.batch = append(l.batch, article)
+	l.queued = append(l.queued, queuedRecord{line: line})
+	if len(l.batch) >= l.batchSize {
+		return l.flush(ctx)
+	}
+	return nil
+}
+
+// Delete deletes articles by PMID. Queued articles are inserted first so
+// that deletions apply in source order.
+func (l *Loader) Delete(ctx context.Context, line int, pmids []string) error {
+	if err := l.flush(ctx); err != nil {
+		return err
+	}
+
+	deleted, err := l.writer.DeleteArticles(ctx, pmids)
 	if err != nil {
-		return fmt.Errorf("failed to parse JSONL: %w", err)
+		return fmt.Errorf("failed to delete articles on line %d: %w", line, err)
+	}
+
+	l.report.Deleted += deleted
+	l.logger.Debug("deleted articles", "line", line, "requested", len(pmids), "deleted", deleted)
+	return nil
+}
+
+// Reject records a record that could not be read. It returns an error in
+// strict mode.
+func (l *Loader) Reject(line int, pmid, reason string) error {
+	if l.strict {
+		return fmt.Errorf("invalid record on line %d: %s", line, reason)
 	}
 
-	// Insert into repository - use type assertion with interface check
-	// We'll need to add a method to insert articles in the interface
-	// For now, we'll use a type assertion approach
-	type articleInserter interface {
-		InsertArticles(ctx context.Context, articles []*domain.Article) error
+	l.report.AddFailure(domain.LoadFailure{Source: l.source, Line: line, PMID: pmid, Reason: reason})
+	l.logger.Warn("skipping invalid record", "source", l.source, "line", line, "pmid", pmid, "reason", reason)
+	return nil
+}
+
+// BeginSource attributes the records that follow to the named source
+func (l *Loader) BeginSource(name string) {
+	l.source = name
+	l.sourceStart = l.counts()
+}
+
+// EndSource stores the articles queued from the current source and adds its
+// counts to the report
+func (l *Loader) EndSource(ctx context.Context) (domain.SourceReport, error) {
+	if err := l.flush(ctx); err != nil {
+		return domain.SourceReport{}, err
 	}
-	
-	if inserter, ok := repo.(articleInserter); ok {
-		if err := inserter.InsertArticles(ctx, articles); err != nil {
-			return fmt.Errorf("failed to insert articles: %w", err)
-		}
+
+	end := l.counts()
+	summary := domain.SourceReport{
+		Source:     l.source,
+		Accepted:   end.Accepted - l.sourceStart.Accepted,
+		Rejected:   end.Rejected - l.sourceStart.Rejected,
+		Duplicates: end.Duplicates - l.sourceStart.Duplicates,
+		Deleted:    end.Deleted - l.sourceStart.Deleted,
 	}
+	l.report.Sources = append(l.report.Sources, summary)
+	l.source = ""
+	return summary, nil
+}
+
+// SkipSource records a source that was not read
+func (l *Loader) SkipSource(name string) {
+	l.report.Sources = append(l.report.Sources, domain.SourceReport{Source: name, Skipped: true})
+}
+
+func (l *Loader) counts() domain.SourceReport {
+	return domain.SourceReport{
+		Accepted:   l.report.Accepted,
+		Rejected:   l.report.Rejected,
+		Duplicates: l.report.Duplicates,
+		Deleted:    l.report.Deleted,
+	}
+}
 
-	logger.Info("articles loaded successfully", "count", len(articles))
+// Close inserts the remaining queued articles and completes the report
+func (l *Loader) Close(ctx context.Context) error {
+	if err := l.flush(ctx); err != nil {
+		return err
+	}
+	l.report.FinishedAt = time.Now().UTC()
 	return nil
 }
 
-// parseJSONL parses JSONL (JSON Lines) format
-func parseJSONL(data []byte) ([]*domain.Article, error) {
-	var articles []*domain.Article
+func (l *Loader) flush(ctx context.Context) error {
+	if len(l.batch) == 0 {
+		return nil
+	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
+	batch, queued := l.batch, l.queued
+	l.batch = make([]*domain.Article, 0, l.batchSize)
+	l.queued = make([]queuedRecord, 0, l.batchSize)
+
+	replaced, err := l.writer.InsertArticles(ctx, batch)
+	if err == nil {
+		l.count(len(batch), replaced)
+		l.logger.Debug("inserted batch", "size", len(batch), "replaced", replaced)
+		return nil
+	}
+	if l.strict || ctx.Err() != nil {
+		return fmt.Errorf("failed to insert articles: %w", err)
+	}
+
+	// Insert the batch one article at a time to find the rows at fault
+	l.logger.Warn("batch insert failed, retrying articles one by one", "size", len(batch), "error", err)
+	for i, article := range batch {
+		replaced, err := l.writer.InsertArticles(ctx, batch[i:i+1])
+		if err == nil {
+			l.count(1, replaced)
 			continue
 		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to insert articles: %w", err)
+		}
+		l.report.AddFailure(domain.LoadFailure{Source: l.source, Line: queued[i].line, PMID: article.PMID, Reason: err.Error()})
+		l.logger.Warn("skipping article that could not be stored", "source", l.source, "line", queued[i].line, "pmid", article.PMID, "error", err)
+	}
+	return nil
+}
+
+// count adds stored articles to the report; those that replaced a stored
+// article are duplicates
+func (l *Loader) count(stored, replaced int) {
+	l.report.Accepted += stored - replaced
+	l.report.Duplicates += replaced
+}
+
+// LoadJSONL streams JSONL (JSON Lines) articles from r into the loader. Only
+// one line is held in memory at a time, and lines may be of any length.
+func LoadJSONL(ctx context.Context, r io.Reader, loader RecordSink) error {
+	reader := bufio.NewReader(r)
 
-		var article domain.Article
-		if err := json.Unmarshal(line, &article); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal article: %w", err)
+	for lineNum := 1; ; lineNum++ {
+		// ReadBytes grows its buffer as needed, unlike bufio.Scanner which
+		// fails on lines over 64KB
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read JSONL: %w", readErr)
 		}
 
-		articles = append(articles, &article)
-	}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var article domain.Article
+			var err error
+			if jsonErr := json.Unmarshal(line, &article); jsonErr != nil {
+				err = loader.Reject(lineNum, "", fmt.Sprintf("malformed JSON: %v", jsonErr))
+			} else {
+				err = loader.Add(ctx, lineNum, &article)
+			}
+			if err != nil {
+				return err
+			}
+		}
 
-	if err := scanner.Err(); err != nil && err != io.EOF {
-		return nil, fmt.Errorf("failed to scan JSONL: %w", err)
+		if readErr != nil {
+			return nil
+		}
 	}
-
-	return articles, nil
 }
-
@@ -0,0 +1,223 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"pubmed-api/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records the batches and deletions it is given, keeps the
+// articles it stores and fails any batch containing a PMID in failPMIDs
+type recordingWriter struct {
+	batches   [][]*domain.Article
+	deletions [][]string
+	stored    map[string]*domain.Article
+	failPMIDs map[string]bool
+}
+
+func (r *recordingWriter) InsertArticles(ctx context.Context, articles []*domain.Article) (int, error) {
+	for _, article := range articles {
+		if r.failPMIDs[article.PMID] {
+			return 0, errors.New("constraint failed")
+		}
+	}
+	r.batches = append(r.batches, articles)
+
+	if r.stored == nil {
+		r.stored = make(map[string]*domain.Article)
+	}
+	replaced := 0
+	for _, article := range articles {
+		if _, ok := r.stored[article.PMID]; ok {
+			replaced++
+		}
+		r.stored[article.PMID] = article
+	}
+	return replaced, nil
+}
+
+func (r *recordingWriter) DeleteArticles(ctx context.Context, pmids []string) (int, error) {
+	r.deletions = append(r.deletions, pmids)
+
+	deleted := 0
+	for _, pmid := range pmids {
+		if _, ok := r.stored[pmid]; ok {
+			delete(r.stored, pmid)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *recordingWriter) pmids() []string {
+	var pmids []string
+	for _, batch := range r.batches {
+		for _, article := range batch {
+			pmids = append(pmids, article.PMID)
+		}
+	}
+	return pmids
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func loadJSONL(t *testing.T, data string, writer *recordingWriter, cfg *Config) (*domain.LoadReport, error) {
+	t.Helper()
+
+	loader := NewLoader(writer, cfg, discardLogger())
+	if err := LoadJSONL(context.Background(), strings.NewReader(data), loader); err != nil {
+		return nil, err
+	}
+	if err := loader.Close(context.Background()); err != nil {
+		return nil, err
+	}
+	return loader.Report(), nil
+}
+
+func TestLoadJSONL_Batches(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 7; i++ {
+		lines = append(lines, fmt.Sprintf(`{"pmid":"%d","title":"Article %d"}`, i, i))
+	}
+	// Blank lines are skipped and the last line needs no newline
+	data := strings.Join(lines[:3], "\n") + "\n\n" + strings.Join(lines[3:], "\r\n")
+
+	writer := &recordingWriter{}
+	report, err := loadJSONL(t, data, writer, &Config{LoadBatchSize: 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, report.Accepted)
+	require.Len(t, writer.batches, 3)
+	assert.Len(t, writer.batches[0], 3)
+	assert.Len(t, writer.batches[1], 3)
+	assert.Len(t, writer.batches[2], 1)
+	assert.Equal(t, "7", writer.batches[2][0].PMID)
+}
+
+func TestLoadJSONL_LongLines(t *testing.T) {
+	abstract := strings.Repeat("ibuprofen ", 100_000)
+	data := `{"pmid":"1","title":"Long","abstract":"` + abstract + `"}` + "\n" + `{"pmid":"2","title":"Short"}`
+
+	writer := &recordingWriter{}
+	report, err := loadJSONL(t, data, writer, &Config{LoadBatchSize: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Accepted)
+	require.Len(t, writer.batches, 1)
+	assert.Equal(t, abstract, writer.batches[0][0].Abstract)
+}
+
+func TestLoadJSONL_Lenient(t *testing.T) {
+	data := strings.Join([]string{
+		`{"pmid":"1","title":"Valid","pub_year":2020,"doi":"10.1000/abc.123"}`,
+		`{"pmid":`,
+		`{"pmid":"","title":"No PMID"}`,
+		`{"pmid":"4","title":"","pub_year":3020}`,
+		`{"pmid":"5","title":"Bad DOI","doi":"doi:10.1000/x"}`,
+		`{"pmid":"1","title":"Valid, revised"}`,
+		`{"pmid":"7","title":"Rejected by the database"}`,
+		`{"pmid":"8","title":"Valid too"}`,
+	}, "\n")
+
+	writer := &recordingWriter{failPMIDs: map[string]bool{"7": true}}
+	report, err := loadJSONL(t, data, writer, &Config{LoadBatchSize: 10})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 5, report.Rejected)
+	assert.Equal(t, []string{"1", "1", "8"}, writer.pmids())
+
+	require.Len(t, report.Failures, 5)
+	assert.Equal(t, 2, report.Failures[0].Line)
+	assert.Contains(t, report.Failures[0].Reason, "malformed JSON")
+	assert.Equal(t, domain.LoadFailure{Line: 3, Reason: "pmid is empty"}, report.Failures[1])
+	assert.Equal(t, "4", report.Failures[2].PMID)
+	assert.Contains(t, report.Failures[2].Reason, "title is empty; pub_year 3020")
+	assert.Contains(t, report.Failures[3].Reason, "doi")
+	assert.Equal(t, domain.LoadFailure{Line: 7, PMID: "7", Reason: "constraint failed"}, report.Failures[4])
+	assert.False(t, report.FinishedAt.IsZero())
+}
+
+func TestLoadJSONL_Strict(t *testing.T) {
+	data := `{"pmid":"1","title":"One"}` + "\n" + `{"pmid":"2","title":"Two"}` + "\n" + `{"pmid":` + "\n"
+
+	writer := &recordingWriter{}
+	_, err := loadJSONL(t, data, writer, &Config{LoadBatchSize: 1, LoadStrict: true})
+
+	assert.ErrorContains(t, err, "line 3")
+	assert.Equal(t, []string{"1", "2"}, writer.pmids(), "batches before the bad line are kept")
+}
+
+// historyRepository is an ArticleRepository that stores articles with a
+// recordingWriter and keeps a load history
+type historyRepository struct {
+	recordingWriter
+	history map[string]*domain.LoadRecord
+}
+
+func (r *historyRepository) FindByID(ctx context.Context, pmid string) (*domain.Article, error) {
+	return r.stored[pmid], nil
+}
+
+func (r *historyRepository) FindByIDs(ctx context.Context, pmids []string) ([]*domain.Article, error) {
+	var articles []*domain.Article
+	for _, pmid := range pmids {
+		if article, ok := r.stored[pmid]; ok {
+			articles = append(articles, article)
+		}
+	}
+	return articles, nil
+}
+
+func (r *historyRepository) Search(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
+	return &domain.SearchResult{}, nil
+}
+
+func (r *historyRepository) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	return &domain.Stats{}, nil
+}
+
+func (r *historyRepository) LoadData(ctx context.Context, dataPath string) error {
+	return nil
+}
+
+func (r *historyRepository) FindLoad(ctx context.Context, source string) (*domain.LoadRecord, error) {
+	return r.history[source], nil
+}
+
+func (r *historyRepository) RecordLoad(ctx context.Context, record *domain.LoadRecord) error {
+	r.history[record.Source] = record
+	return nil
+}
+
+func TestLoadArticles_SkipsLoadedSource(t *testing.T) {
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataPath: "../pubmed/testdata/pubmed_update.xml"}
+
+	report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.False(t, report.Skipped)
+	assert.Equal(t, 2, report.Accepted)
+
+	record := repo.history[cfg.DataPath]
+	require.NotNil(t, record)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", record.Fingerprint)
+	assert.Equal(t, 2, record.Accepted)
+
+	// Replaying the same file is a no-op
+	report, err = LoadArticles(context.Background(), repo, cfg, discardLogger())
+	require.NoError(t, err)
+	assert.True(t, report.Skipped)
+	assert.Len(t, repo.batches, 2)
+}
@@ -0,0 +1,471 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"pubmed-api/internal/domain"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourceBufferSize is how many records of a source may be parsed ahead of
+// the loader
+const sourceBufferSize = 256
+
+// dataExtensions are the file extensions loaded from a DATA_PATH directory,
+// each optionally followed by .gz
+var dataExtensions = []string{".jsonl", ".ndjson", ".xml", ".nbib", ".txt"}
+
+// dataSource is a file or object to load
+type dataSource struct {
+	name string
+	// version identifies the revision of the source without reading it, if
+	// it can be found: the ETag of an S3 object, the size and modification
+	// time of a file
+	version string
+	open    func(ctx context.Context) (io.ReadCloser, error)
+	// spool is a temporary copy of the source that open reads instead,
+	// removed once the load is done
+	spool string
+}
+
+// openDataSources picks the sources to load (S3, local files, or embedded)
+// and returns them with a name for the whole load
+func openDataSources(ctx context.Context, cfg *Config, logger *slog.Logger) (string, []dataSource) {
+	// Priority 1: S3
+	if cfg.DataS3URL != "" {
+		sources, err := func() ([]dataSource, error) {
+			client, err := NewS3Client(ctx, cfg.DataS3Endpoint)
+			if err != nil {
+				return nil, err
+			}
+			return s3Sources(ctx, client, cfg.DataS3URL, logger)
+		}()
+		switch {
+		case err != nil:
+			logger.Warn("failed to load from S3, falling back", "error", err)
+		case len(sources) == 0:
+			logger.Warn("no data files found in S3, falling back", "url", cfg.DataS3URL)
+		default:
+			return cfg.DataS3URL, sources
+		}
+	}
+
+	// Priority 2: Local files
+	files, err := ResolveDataPath(cfg.DataPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		logger.Warn("failed to load from local files, falling back", "error", err)
+	case len(files) == 0:
+		logger.Warn("no data files found, falling back", "path", cfg.DataPath)
+	default:
+		sources := make([]dataSource, len(files))
+		for i, file := range files {
+			sources[i] = localSource(file)
+		}
+		return cfg.DataPath, sources
+	}
+
+	// Priority 3: Embedded fallback
+	logger.Info("using embedded fallback data")
+	checksum := sha256.Sum256(embeddedData)
+	return "embedded", []dataSource{{
+		name:    "embedded",
+		version: "sha256:" + hex.EncodeToString(checksum[:]),
+		open: func(ctx context.Context) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(embeddedData)), nil
+		},
+	}}
+}
+
+func localSource(path string) dataSource {
+	source := dataSource{
+		name: path,
+		open: func(ctx context.Context) (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+	}
+	if info, err := os.Stat(path); err == nil {
+		source.version = fmt.Sprintf("size=%d mtime=%s", info.Size(), info.ModTime().UTC().Format(time.RFC3339Nano))
+	}
+	return source
+}
+
+// ResolveDataPath returns the files a DATA_PATH refers to, in the order they
+// are loaded. The path may be:
+//
+//   - a file
+//   - a directory, whose data files (.jsonl, .ndjson, .xml, .nbib and .txt,
+//     each optionally gzipped) are loaded in name order; subdirectories and
+//     hidden files are ignored
+//   - a glob such as data/pubmed24n*.xml.gz, whose matches are loaded in
+//     name order
+//
+// Ordering matters for PubMed update files, which must be applied in
+// sequence. An error wrapping fs.ErrNotExist is returned if a file or
+// directory path does not exist.
+func ResolveDataPath(path string) ([]string, error) {
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DATA_PATH pattern %q: %w", path, err)
+		}
+
+		files := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files = append(files, match)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	// ReadDir returns entries sorted by name
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") && isDataFile(entry.Name()) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+func isDataFile(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".gz")
+	for _, ext := range dataExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordOp is a record read from a source, or the end of the source
+type recordOp struct {
+	kind    recordOpKind
+	line    int
+	article *domain.Article
+	pmids   []string
+	pmid    string
+	reason  string
+	// fingerprint and err are set on the end of a source
+	fingerprint string
+	err         error
+}
+
+type recordOpKind int
+
+const (
+	opAdd recordOpKind = iota
+	opDelete
+	opReject
+	opEnd
+)
+
+// streamSink is a RecordSink that passes records on to the loader
+// goroutine
+type streamSink struct {
+	ctx    context.Context
+	stream chan<- recordOp
+}
+
+func (s *streamSink) send(op recordOp) error {
+	select {
+	case s.stream <- op:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *streamSink) Add(ctx context.Context, line int, article *domain.Article) error {
+	return s.send(recordOp{kind: opAdd, line: line, article: article})
+}
+
+func (s *streamSink) Delete(ctx context.Context, line int, pmids []string) error {
+	return s.send(recordOp{kind: opDelete, line: line, pmids: pmids})
+}
+
+func (s *streamSink) Reject(line int, pmid, reason string) error {
+	return s.send(recordOp{kind: opReject, line: line, pmid: pmid, reason: reason})
+}
+
+// loadSources parses up to workers sources at a time but applies their
+// records to the loader one source after another, in order, so the outcome
+// is the same as loading them sequentially. Each source is stored and
+// recorded in the load history before the next one is applied.
+//
+// Sources already in the history are skipped, up to the first one that is
+// new or whose content changed. That source and every source after it are
+// loaded again: later sources may revise or delete its articles, so they
+// must be applied after it. Reloading only upserts and applies deletion
+// blocks: articles dropped from a changed source stay stored, so sources
+// are treated as append-only and removals must come as deletions.
+func loadSources(ctx context.Context, sources []dataSource, loader *Loader, history LoadHistory, workers int, logger *slog.Logger) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	defer func() {
+		for _, source := range sources {
+			if source.spool != "" {
+				os.Remove(source.spool)
+			}
+		}
+	}()
+
+	skip := make([]bool, len(sources))
+	skipped := 0
+	if history != nil {
+		for i := range sources {
+			source := &sources[i]
+			loaded, err := sourceLoaded(ctx, source, history, logger)
+			if err != nil {
+				return err
+			}
+			if !loaded {
+				if later := len(sources) - i - 1; later > 0 {
+					logger.Info("loading the sources that follow again", "source", source.name, "count", later)
+				}
+				break
+			}
+			skip[i] = true
+			skipped++
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	// Sources are handed to the workers in order, so the source the loader
+	// waits for is always being parsed, while later ones can only run ahead
+	// by sourceBufferSize records
+	streams := make([]chan recordOp, len(sources))
+	jobs := make(chan int)
+	for i := range sources {
+		if !skip[i] {
+			streams[i] = make(chan recordOp, sourceBufferSize)
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range sources {
+			if skip[i] {
+				continue
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				parseSource(ctx, sources[i], streams[i])
+			}
+		}()
+	}
+
+	for i, source := range sources {
+		if skip[i] {
+			loader.SkipSource(source.name)
+			continue
+		}
+		if err := applySource(ctx, source, streams[i], loader, history); err != nil {
+			return err
+		}
+	}
+
+	loader.Report().Skipped = skipped > 0 && skipped == len(sources)
+	return nil
+}
+
+// sourceLoaded reports whether the load history holds the current content
+// of a source. A source whose version changed is copied to a spool file to
+// compare its checksum, so that touching a file or downloading it again does
+// not count as a change; the new version is then recorded. A source that did
+// change is loaded from the spool rather than read a second time.
+func sourceLoaded(ctx context.Context, source *dataSource, history LoadHistory, logger *slog.Logger) (bool, error) {
+	previous, err := history.FindLoad(ctx, source.name)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case previous == nil:
+		return false, nil
+	case previous.Version == source.version:
+		logger.Info("source already loaded, skipping", "source", source.name, "loaded_at", previous.LoadedAt)
+		return true, nil
+	}
+
+	spool, fingerprint, err := spoolSource(ctx, *source)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", source.name, err)
+	}
+	source.spool = spool
+	if fingerprint != previous.Fingerprint {
+		logger.Info("source changed since it was loaded, reloading", "source", source.name, "loaded_version", previous.Version, "version", source.version)
+		source.open = func(ctx context.Context) (io.ReadCloser, error) {
+			return os.Open(spool)
+		}
+		return false, nil
+	}
+
+	logger.Info("source content unchanged since it was loaded, skipping", "source", source.name, "loaded_at", previous.LoadedAt, "version", source.version)
+	record := *previous
+	record.Version = source.version
+	return true, history.RecordLoad(ctx, &record)
+}
+
+// spoolSource copies a source to a temporary file and returns its path with
+// the checksum of the content
+func spoolSource(ctx context.Context, source dataSource) (string, string, error) {
+	r, err := source.open(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "pubmed-source-*")
+	if err != nil {
+		return "", "", err
+	}
+
+	checksum := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, checksum), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	return f.Name(), fingerprint(checksum), nil
+}
+
+// fingerprint formats a checksum as recorded in the load history
+func fingerprint(checksum hash.Hash) string {
+	return "sha256:" + hex.EncodeToString(checksum.Sum(nil))
+}
+
+// parseSource reads a source into its stream, ending it with the checksum
+// of its content or the error that stopped it
+func parseSource(ctx context.Context, source dataSource, stream chan<- recordOp) {
+	defer close(stream)
+	sink := &streamSink{ctx: ctx, stream: stream}
+
+	checksum := sha256.New()
+	err := func() error {
+		r, err := source.open(ctx)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		data := io.TeeReader(r, checksum)
+		if err := LoadStream(ctx, data, sink); err != nil {
+			return err
+		}
+		// Read anything the parser left so the checksum covers the whole source
+		_, err = io.Copy(io.Discard, data)
+		return err
+	}()
+
+	sink.send(recordOp{kind: opEnd, fingerprint: fingerprint(checksum), err: err})
+}
+
+// applySource passes the records of a source to the loader, stores them and
+// records the source in the load history
+func applySource(ctx context.Context, source dataSource, stream <-chan recordOp, loader *Loader, history LoadHistory) error {
+	name := source.name
+	loader.BeginSource(name)
+
+	for {
+		var op recordOp
+		var ok bool
+		select {
+		case op, ok = <-stream:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if !ok {
+			return ctx.Err()
+		}
+
+		var err error
+		switch op.kind {
+		case opAdd:
+			err = loader.Add(ctx, op.line, op.article)
+		case opDelete:
+			err = loader.Delete(ctx, op.line, op.pmids)
+		case opReject:
+			err = loader.Reject(op.line, op.pmid, op.reason)
+		case opEnd:
+			if op.err != nil {
+				return fmt.Errorf("failed to load %s: %w", name, op.err)
+			}
+			return endSource(ctx, loader, history, source.version, op.fingerprint)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", name, err)
+		}
+	}
+}
+
+func endSource(ctx context.Context, loader *Loader, history LoadHistory, version, fingerprint string) error {
+	summary, err := loader.EndSource(ctx)
+	if err != nil {
+		return err
+	}
+	if history == nil {
+		return nil
+	}
+
+	return history.RecordLoad(ctx, &domain.LoadRecord{
+		Source:      summary.Source,
+		Version:     version,
+		Fingerprint: fingerprint,
+		LoadedAt:    time.Now().UTC(),
+		Accepted:    summary.Accepted,
+		Rejected:    summary.Rejected,
+		Duplicates:  summary.Duplicates,
+		Deleted:     summary.Deleted,
+	})
+}
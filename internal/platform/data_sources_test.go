@@ -0,0 +1,209 @@
+package platform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"pubmed-api/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDataDir writes the given files to a temporary directory
+func writeDataDir(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+	return dir
+}
+
+func TestResolveDataPath(t *testing.T) {
+	dir := writeDataDir(t, map[string][]byte{
+		"pubmed24n0002.xml.gz": nil,
+		"pubmed24n0001.xml.gz": nil,
+		"extra.jsonl":          nil,
+		"README.md":            nil,
+		".hidden.jsonl":        nil,
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "archive.jsonl"), 0o755))
+
+	files, err := ResolveDataPath(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "extra.jsonl"),
+		filepath.Join(dir, "pubmed24n0001.xml.gz"),
+		filepath.Join(dir, "pubmed24n0002.xml.gz"),
+	}, files)
+
+	files, err = ResolveDataPath(filepath.Join(dir, "pubmed24n*"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "pubmed24n0001.xml.gz"),
+		filepath.Join(dir, "pubmed24n0002.xml.gz"),
+	}, files)
+
+	files, err = ResolveDataPath(filepath.Join(dir, "README.md"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "README.md")}, files)
+
+	files, err = ResolveDataPath(filepath.Join(dir, "*.nbib"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	_, err = ResolveDataPath(filepath.Join(dir, "missing.jsonl"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadArticles_Directory(t *testing.T) {
+	update, err := os.ReadFile("../pubmed/testdata/pubmed_update.xml")
+	require.NoError(t, err)
+
+	dir := writeDataDir(t, map[string][]byte{
+		"01_baseline.jsonl":   []byte(`{"pmid":"31234567","title":"Original title"}` + "\n" + `{"pmid":"9876543","title":"To be deleted"}` + "\n"),
+		"02_extra.jsonl.gz":   gzipData(t, []byte(`{"pmid":"2","title":"Two"}`+"\n"+`{"pmid":"3","title":""}`+"\n")),
+		"03_update.xml.gz":    gzipData(t, update),
+		"04_unrelated.csv.gz": []byte("ignored"),
+	})
+
+	// Run several times so that parsing order varies between runs
+	for run := 0; run < 10; run++ {
+		repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+		cfg := &Config{DataPath: dir, LoadBatchSize: 1, LoadWorkers: 3}
+
+		report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+		require.NoError(t, err)
+
+		// The update is applied after the baseline it revises
+		assert.Equal(t, "Ibuprofen versus paracetamol for acute postoperative pain: a randomised controlled trial.", repo.stored["31234567"].Title)
+		assert.Equal(t, "Ibuprofen in children with fever, reissued.", repo.stored["9876543"].Title)
+		assert.Len(t, repo.stored, 3)
+
+		assert.Equal(t, dir, report.Source)
+		// 9876543 is deleted before being added back, which counts as new
+		assert.Equal(t, 4, report.Accepted)
+		assert.Equal(t, 1, report.Duplicates)
+		assert.Equal(t, 1, report.Rejected)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Equal(t, []domain.SourceReport{
+			{Source: filepath.Join(dir, "01_baseline.jsonl"), Accepted: 2},
+			{Source: filepath.Join(dir, "02_extra.jsonl.gz"), Accepted: 1, Rejected: 1},
+			{Source: filepath.Join(dir, "03_update.xml.gz"), Accepted: 1, Duplicates: 1, Deleted: 1},
+		}, report.Sources)
+		require.Len(t, report.Failures, 1)
+		assert.Equal(t, filepath.Join(dir, "02_extra.jsonl.gz"), report.Failures[0].Source)
+		assert.Equal(t, 2, report.Failures[0].Line)
+
+		assert.Len(t, repo.history, 3)
+	}
+}
+
+func TestLoadArticles_DirectoryReloadsChangedFiles(t *testing.T) {
+	dir := writeDataDir(t, map[string][]byte{
+		"a.jsonl": []byte(`{"pmid":"1","title":"One"}` + "\n"),
+	})
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataPath: dir}
+	a, b := filepath.Join(dir, "a.jsonl"), filepath.Join(dir, "b.jsonl")
+
+	load := func() *domain.LoadReport {
+		t.Helper()
+		repo.batches = nil
+		report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+		require.NoError(t, err)
+		return report
+	}
+
+	load()
+	assert.Equal(t, []string{"1"}, repo.pmids())
+	assert.NotEmpty(t, repo.history[a].Version)
+
+	// A new file is loaded on its own
+	require.NoError(t, os.WriteFile(b, []byte(`{"pmid":"2","title":"Two"}`+"\n"), 0o644))
+	report := load()
+	assert.False(t, report.Skipped)
+	assert.Equal(t, []string{"2"}, repo.pmids())
+	assert.Equal(t, []domain.SourceReport{
+		{Source: a, Skipped: true},
+		{Source: b, Accepted: 1},
+	}, report.Sources)
+
+	// A changed file is loaded again, followed by the files after it
+	require.NoError(t, os.WriteFile(a, []byte(`{"pmid":"1","title":"One, revised"}`+"\n"), 0o644))
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(a, later, later))
+	load()
+	assert.Equal(t, []string{"1", "2"}, repo.pmids())
+	assert.Equal(t, "One, revised", repo.stored["1"].Title)
+
+	// Nothing is read when nothing changed
+	report = load()
+	assert.True(t, report.Skipped)
+	assert.Empty(t, repo.pmids())
+}
+
+func TestLoadArticles_DirectoryKeepsUpdateOrder(t *testing.T) {
+	update := `<PubmedArticleSet>
+<PubmedArticle><MedlineCitation><PMID>1</PMID><Article><Journal><Title>J1</Title></Journal><ArticleTitle>One, revised</ArticleTitle></Article></MedlineCitation></PubmedArticle>
+<DeleteCitation><PMID>2</PMID></DeleteCitation>
+</PubmedArticleSet>`
+	dir := writeDataDir(t, map[string][]byte{
+		"01_update.jsonl": []byte(`{"pmid":"1","title":"One"}` + "\n" + `{"pmid":"2","title":"Two"}` + "\n"),
+		"02_update.xml":   []byte(update),
+	})
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+	cfg := &Config{DataPath: dir}
+	older := filepath.Join(dir, "01_update.jsonl")
+
+	load := func() *domain.LoadReport {
+		t.Helper()
+		repo.batches, repo.deletions = nil, nil
+		report, err := LoadArticles(context.Background(), repo, cfg, discardLogger())
+		require.NoError(t, err)
+		return report
+	}
+
+	load()
+	assert.Equal(t, "One, revised", repo.stored["1"].Title)
+	assert.NotContains(t, repo.stored, "2")
+	version := repo.history[older].Version
+
+	// Touching the older file does not apply it again over the newer one
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(older, later, later))
+	report := load()
+	assert.True(t, report.Skipped)
+	assert.Empty(t, repo.pmids())
+	assert.Equal(t, "One, revised", repo.stored["1"].Title)
+	assert.NotContains(t, repo.stored, "2")
+	assert.NotEqual(t, version, repo.history[older].Version, "the new version is recorded")
+
+	// Changing it reloads both files in order
+	require.NoError(t, os.WriteFile(older, []byte(`{"pmid":"1","title":"One"}`+"\n"+`{"pmid":"2","title":"Two"}`+"\n"+`{"pmid":"3","title":"Three"}`+"\n"), 0o644))
+	report = load()
+	assert.Equal(t, []string{"1", "2", "3", "1"}, repo.pmids())
+	assert.Equal(t, [][]string{{"2"}}, repo.deletions)
+	assert.Equal(t, "One, revised", repo.stored["1"].Title)
+	assert.NotContains(t, repo.stored, "2")
+	assert.Contains(t, repo.stored, "3")
+	assert.Len(t, report.Sources, 2)
+}
+
+func TestLoadArticles_DirectoryStrict(t *testing.T) {
+	dir := writeDataDir(t, map[string][]byte{
+		"a.jsonl": []byte(`{"pmid":"1","title":"One"}` + "\n"),
+		"b.jsonl": []byte(`{"pmid":"2","title":""}` + "\n"),
+		"c.jsonl": []byte(`{"pmid":"3","title":"Three"}` + "\n"),
+	})
+	repo := &historyRepository{history: map[string]*domain.LoadRecord{}}
+
+	_, err := LoadArticles(context.Background(), repo, &Config{DataPath: dir, LoadStrict: true, LoadWorkers: 3}, discardLogger())
+	assert.ErrorContains(t, err, "b.jsonl")
+	assert.Equal(t, []string{"1"}, repo.pmids(), "files after the failing one are not applied")
+	assert.Len(t, repo.history, 1)
+}
@@ -0,0 +1,90 @@
+package platform
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"pubmed-api/internal/pubmed"
+)
+
+// sniffSize is how much of a stream is examined to detect its format
+const sniffSize = 512
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// LoadStream detects the format of r and streams its articles into the
+// loader. JSONL, PubMed XML (baseline and update files, efetch output) and
+// MEDLINE text (.nbib) are recognized from their content, so file names do
+// not matter, and gzip-compressed input is decompressed first.
+func LoadStream(ctx context.Context, r io.Reader, loader RecordSink) error {
+	br := bufio.NewReader(r)
+
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to decompress data: %w", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	head, err := br.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	head = bytes.TrimLeft(head, " \t\r\n\uFEFF")
+
+	switch {
+	case len(head) == 0 || head[0] == '{':
+		return LoadJSONL(ctx, br, loader)
+	case head[0] == '<':
+		return LoadPubMedXML(ctx, br, loader)
+	case bytes.HasPrefix(head, []byte("PMID-")):
+		return LoadMedline(ctx, br, loader)
+	default:
+		return fmt.Errorf("unrecognized data format: expected JSONL, PubMed XML or MEDLINE text")
+	}
+}
+
+// LoadPubMedXML streams the citations of a PubmedArticleSet document into
+// the loader. Citations are upserted and the DeleteCitation blocks of update
+// files are applied in document order.
+func LoadPubMedXML(ctx context.Context, r io.Reader, loader RecordSink) error {
+	d := pubmed.NewDecoder(r)
+	d.OnDelete(func(pmids []string) error {
+		return loader.Delete(ctx, d.Line(), pmids)
+	})
+	for {
+		citation, err := d.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := loader.Add(ctx, d.Line(), citation.Article()); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadMedline streams MEDLINE text records into the loader
+func LoadMedline(ctx context.Context, r io.Reader, loader RecordSink) error {
+	m := pubmed.NewMedlineReader(r)
+	for {
+		citation, err := m.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := loader.Add(ctx, m.Line(), citation.Article()); err != nil {
+			return err
+		}
+	}
+}
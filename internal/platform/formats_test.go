@@ -0,0 +1,124 @@
+package platform
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"pubmed-api/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestLoadStream_Formats(t *testing.T) {
+	xmlData, err := os.ReadFile("../pubmed/testdata/pubmed_articles.xml")
+	require.NoError(t, err)
+	medlineData, err := os.ReadFile("../pubmed/testdata/medline.nbib")
+	require.NoError(t, err)
+	jsonlData := []byte(`{"pmid":"1","title":"One"}` + "\n" + `{"pmid":"2","title":"Two"}` + "\n")
+
+	tests := []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		{name: "jsonl", data: jsonlData, want: []string{"1", "2"}},
+		{name: "gzipped jsonl", data: gzipData(t, jsonlData), want: []string{"1", "2"}},
+		{name: "pubmed xml", data: xmlData, want: []string{"31234567", "9876543", "11111111"}},
+		{name: "gzipped pubmed xml", data: gzipData(t, xmlData), want: []string{"31234567", "9876543", "11111111"}},
+		{name: "medline", data: medlineData, want: []string{"31234567", "9876543", "11111111"}},
+		{name: "medline with leading blank lines", data: append([]byte("\n\n"), medlineData...), want: []string{"31234567", "9876543", "11111111"}},
+		{name: "empty", data: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &recordingWriter{}
+			loader := NewLoader(writer, &Config{}, discardLogger())
+
+			require.NoError(t, LoadStream(context.Background(), bytes.NewReader(tt.data), loader))
+			require.NoError(t, loader.Close(context.Background()))
+
+			assert.Equal(t, tt.want, writer.pmids())
+			assert.Zero(t, loader.Report().Rejected)
+		})
+	}
+}
+
+func TestLoadStream_XMLArticle(t *testing.T) {
+	f, err := os.Open("../pubmed/testdata/pubmed_articles.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	writer := &recordingWriter{}
+	loader := NewLoader(writer, &Config{}, discardLogger())
+	require.NoError(t, LoadStream(context.Background(), f, loader))
+	require.NoError(t, loader.Close(context.Background()))
+
+	require.NotEmpty(t, writer.batches)
+	article := writer.batches[0][0]
+	assert.Equal(t, "Br J Clin Pharmacol", article.Journal)
+	assert.Equal(t, 2020, article.PubYear)
+	assert.Equal(t, []string{"Smith JA", "Müller K", "POP Trial Investigators"}, article.Authors)
+	assert.Equal(t, "10.1111/bcp.14180", article.DOI)
+}
+
+func TestLoadStream_InvalidRecordLine(t *testing.T) {
+	data := "PMID- 1\nTI  - One\n\nPMID- 2\nTI  - \n"
+
+	loader := NewLoader(&recordingWriter{}, &Config{}, discardLogger())
+	require.NoError(t, LoadStream(context.Background(), strings.NewReader(data), loader))
+
+	require.Len(t, loader.Report().Failures, 1)
+	assert.Equal(t, 4, loader.Report().Failures[0].Line)
+}
+
+func TestLoadStream_UnknownFormat(t *testing.T) {
+	loader := NewLoader(&recordingWriter{}, &Config{}, discardLogger())
+	err := LoadStream(context.Background(), strings.NewReader("pmid,title\n1,One\n"), loader)
+	assert.ErrorContains(t, err, "unrecognized data format")
+}
+
+func TestLoadStream_UpdateFile(t *testing.T) {
+	f, err := os.Open("../pubmed/testdata/pubmed_update.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	writer := &recordingWriter{stored: map[string]*domain.Article{
+		"31234567": {PMID: "31234567", Title: "Original title"},
+		"9876543":  {PMID: "9876543", Title: "To be deleted"},
+	}}
+	loader := NewLoader(writer, &Config{}, discardLogger())
+	require.NoError(t, LoadStream(context.Background(), f, loader))
+	require.NoError(t, loader.Close(context.Background()))
+
+	// The revision is inserted before the deletion, and the article added
+	// after the deletion survives it
+	require.Len(t, writer.batches, 2)
+	assert.Equal(t, "31234567", writer.batches[0][0].PMID)
+	assert.Equal(t, [][]string{{"9876543", "55555555"}}, writer.deletions)
+	assert.Equal(t, "9876543", writer.batches[1][0].PMID)
+
+	assert.Equal(t, "Ibuprofen versus paracetamol for acute postoperative pain: a randomised controlled trial.", writer.stored["31234567"].Title)
+	assert.Equal(t, "Ibuprofen in children with fever, reissued.", writer.stored["9876543"].Title)
+
+	// The revision replaces a stored article
+	report := loader.Report()
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, 1, report.Duplicates)
+	assert.Equal(t, 1, report.Deleted)
+}
@@ -0,0 +1,25 @@
+package platform
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"pubmed-api/internal/mesh"
+)
+
+// LoadMeshTree loads the MeSH hierarchy from a descriptor XML or tree TSV file
+func LoadMeshTree(path string, logger *slog.Logger) (*mesh.Tree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MeSH tree: %w", err)
+	}
+	defer f.Close()
+
+	tree, err := mesh.ParseTree(f)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("loaded MeSH tree", "path", path, "descriptors", tree.Len())
+	return tree, nil
+}
@@ -0,0 +1,140 @@
+package pubmed
+
+import (
+	"pubmed-api/internal/domain"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Citation is a PubMed record with the detail the source formats provide.
+// Article flattens it into the shape the API serves.
+type Citation struct {
+	PMID  string
+	Title string
+	// Abstract holds the abstract sections in order; unstructured abstracts
+	// have a single section without a label
+	Abstract []AbstractSection
+	Authors  []Author
+	// Journal is the full journal title and JournalAbbr the NLM title
+	// abbreviation (MedlineTA), e.g. "J Clin Pharm"
+	Journal      string
+	JournalAbbr  string
+	Volume       string
+	Issue        string
+	Pages        string
+	PubDate      PubDate
+	MeshHeadings []MeshHeading
+	DOI          string
+}
+
+// AbstractSection is one labelled part of a structured abstract
+type AbstractSection struct {
+	// Label is the heading printed in the article, e.g. "METHODS"
+	Label string
+	// Category is the NLM category the label maps to, e.g. "METHODS"
+	Category string
+	Text     string
+}
+
+// Author is a person or, when CollectiveName is set, a group
+type Author struct {
+	LastName       string
+	ForeName       string
+	Initials       string
+	CollectiveName string
+	Affiliations   []string
+}
+
+// Name returns the author as PubMed displays it, e.g. "Smith JA"
+func (a Author) Name() string {
+	if a.CollectiveName != "" {
+		return a.CollectiveName
+	}
+	return strings.TrimSpace(a.LastName + " " + a.Initials)
+}
+
+// PubDate is a journal issue date. Issues are dated with a year, month and
+// day, a season ("Spring") or a free-text MedlineDate ("1998 Dec-1999 Jan");
+// Year is filled in for all of them when a year can be found.
+type PubDate struct {
+	Year        int
+	Month       string
+	Day         string
+	Season      string
+	MedlineDate string
+}
+
+// MeshHeading is a MeSH descriptor with its qualifiers (subheadings)
+type MeshHeading struct {
+	Descriptor MeshTerm
+	Qualifiers []MeshTerm
+}
+
+// MeshTerm is a MeSH descriptor or qualifier
+type MeshTerm struct {
+	UI         string
+	Name       string
+	MajorTopic bool
+}
+
+// Article converts the citation to a domain article. Structured abstract
+// sections are joined as "LABEL: text" paragraphs and MeSH headings are
+// reduced to their descriptor names.
+func (c *Citation) Article() *domain.Article {
+	article := &domain.Article{
+		PMID:      c.PMID,
+		Title:     c.Title,
+		Abstract:  c.AbstractText(),
+		Authors:   make([]string, 0, len(c.Authors)),
+		Journal:   c.JournalAbbr,
+		PubYear:   c.PubDate.Year,
+		MeshTerms: make([]string, 0, len(c.MeshHeadings)),
+		DOI:       c.DOI,
+	}
+
+	if article.Journal == "" {
+		article.Journal = c.Journal
+	}
+
+	for _, author := range c.Authors {
+		if name := author.Name(); name != "" {
+			article.Authors = append(article.Authors, name)
+		}
+	}
+
+	for _, heading := range c.MeshHeadings {
+		if heading.Descriptor.Name != "" {
+			article.MeshTerms = append(article.MeshTerms, heading.Descriptor.Name)
+		}
+	}
+
+	return article
+}
+
+// AbstractText returns the abstract as plain text
+func (c *Citation) AbstractText() string {
+	paragraphs := make([]string, 0, len(c.Abstract))
+	for _, section := range c.Abstract {
+		if section.Text == "" {
+			continue
+		}
+		if section.Label != "" {
+			paragraphs = append(paragraphs, section.Label+": "+section.Text)
+		} else {
+			paragraphs = append(paragraphs, section.Text)
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// parseYear returns the first four-digit number in s, or 0 if there is none
+func parseYear(s string) int {
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }) {
+		if len(field) == 4 {
+			year, _ := strconv.Atoi(field)
+			return year
+		}
+	}
+	return 0
+}
@@ -0,0 +1,222 @@
+package pubmed
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MedlineReader reads citations in the MEDLINE text format, as exported by
+// PubMed (.nbib, .txt): one "TAG - value" field per line, values continued
+// on lines indented by six spaces and records separated by blank lines.
+type MedlineReader struct {
+	reader *bufio.Reader
+	line   int
+	// recordLine is the line the last record returned by Next started on
+	recordLine int
+}
+
+// NewMedlineReader returns a reader of MEDLINE records from r
+func NewMedlineReader(r io.Reader) *MedlineReader {
+	return &MedlineReader{reader: bufio.NewReader(r)}
+}
+
+// Line returns the line the last citation returned by Next started on
+func (m *MedlineReader) Line() int {
+	return m.recordLine
+}
+
+// medlineField is a tag and its value, with continuation lines joined
+type medlineField struct {
+	tag   string
+	value string
+}
+
+// Next returns the next citation, or io.EOF after the last one
+func (m *MedlineReader) Next() (*Citation, error) {
+	var fields []medlineField
+
+	for {
+		line, err := m.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to read MEDLINE data: %w", err)
+		}
+		if line != "" || err == nil {
+			m.line++
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			// A blank line ends the record, if one has started
+			if len(fields) > 0 {
+				return medlineCitation(fields), nil
+			}
+		case strings.HasPrefix(line, "      "):
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("MEDLINE line %d: continuation line outside a field", m.line)
+			}
+			fields[len(fields)-1].value += " " + strings.TrimSpace(line)
+		case len(line) >= 5 && line[4] == '-':
+			if len(fields) == 0 {
+				m.recordLine = m.line
+			}
+			fields = append(fields, medlineField{
+				tag:   strings.TrimSpace(line[:4]),
+				value: strings.TrimSpace(line[5:]),
+			})
+		default:
+			return nil, fmt.Errorf("MEDLINE line %d: expected a \"TAG - value\" field", m.line)
+		}
+
+		if err != nil {
+			if len(fields) > 0 {
+				return medlineCitation(fields), nil
+			}
+			return nil, io.EOF
+		}
+	}
+}
+
+// ParseMedline reads every citation in MEDLINE text
+func ParseMedline(r io.Reader) ([]*Citation, error) {
+	m := NewMedlineReader(r)
+
+	var citations []*Citation
+	for {
+		citation, err := m.Next()
+		if err == io.EOF {
+			return citations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		citations = append(citations, citation)
+	}
+}
+
+func medlineCitation(fields []medlineField) *Citation {
+	c := &Citation{}
+
+	// FAU (full name) and AU (short name) both describe the author that
+	// follows; AD lines belong to the author before them. Older records only
+	// have AU lines.
+	var author *Author
+	pendingFAU := false
+	newAuthor := func() {
+		c.Authors = append(c.Authors, Author{})
+		author = &c.Authors[len(c.Authors)-1]
+	}
+
+	for _, field := range fields {
+		value := field.value
+		switch field.tag {
+		case "PMID":
+			c.PMID = value
+		case "TI":
+			c.Title = value
+		case "TT":
+			// Some non-English articles only have a title in their own language
+			if c.Title == "" {
+				c.Title = value
+			}
+		case "AB":
+			c.Abstract = append(c.Abstract, AbstractSection{Text: value})
+		case "FAU":
+			newAuthor()
+			author.LastName, author.ForeName, _ = strings.Cut(value, ", ")
+			pendingFAU = true
+		case "AU":
+			if pendingFAU {
+				author.Initials = strings.TrimSpace(strings.TrimPrefix(value, author.LastName))
+			} else {
+				newAuthor()
+				if i := strings.LastIndex(value, " "); i > 0 {
+					author.LastName, author.Initials = value[:i], value[i+1:]
+				} else {
+					author.LastName = value
+				}
+			}
+			pendingFAU = false
+		case "CN":
+			newAuthor()
+			author.CollectiveName = value
+			pendingFAU = false
+		case "AD":
+			if author != nil {
+				author.Affiliations = append(author.Affiliations, value)
+			}
+		case "TA":
+			c.JournalAbbr = value
+		case "JT":
+			c.Journal = value
+		case "VI":
+			c.Volume = value
+		case "IP":
+			c.Issue = value
+		case "PG":
+			c.Pages = value
+		case "DP":
+			c.PubDate = medlineDate(value)
+		case "MH":
+			c.MeshHeadings = append(c.MeshHeadings, medlineMeshHeading(value))
+		case "LID", "AID":
+			if doi, ok := strings.CutSuffix(value, " [doi]"); ok && c.DOI == "" {
+				c.DOI = strings.TrimSpace(doi)
+			}
+		}
+	}
+
+	return c
+}
+
+var medlineMonths = map[string]bool{
+	"Jan": true, "Feb": true, "Mar": true, "Apr": true, "May": true, "Jun": true,
+	"Jul": true, "Aug": true, "Sep": true, "Oct": true, "Nov": true, "Dec": true,
+}
+
+var medlineSeasons = map[string]bool{
+	"Spring": true, "Summer": true, "Fall": true, "Autumn": true, "Winter": true,
+}
+
+// medlineDate parses a DP value such as "2020 Apr 15", "2019 Spring" or
+// "1998 Dec-1999 Jan"; anything but a plain date is kept as a MedlineDate
+func medlineDate(value string) PubDate {
+	date := PubDate{Year: parseYear(value)}
+
+	parts := strings.Fields(value)
+	switch {
+	case len(parts) == 1 && len(parts[0]) == 4 && date.Year != 0:
+	case len(parts) == 2 && medlineSeasons[parts[1]]:
+		date.Season = parts[1]
+	case len(parts) >= 2 && len(parts) <= 3 && medlineMonths[parts[1]]:
+		date.Month = parts[1]
+		if len(parts) == 3 {
+			date.Day = parts[2]
+		}
+	default:
+		date.MedlineDate = value
+	}
+	return date
+}
+
+// medlineMeshHeading parses an MH value such as
+// "*Ibuprofen/administration & dosage/*therapeutic use", where * marks a
+// major topic
+func medlineMeshHeading(value string) MeshHeading {
+	parts := strings.Split(value, "/")
+
+	term := func(s string) MeshTerm {
+		s = strings.TrimSpace(s)
+		name := strings.TrimPrefix(s, "*")
+		return MeshTerm{Name: name, MajorTopic: name != s}
+	}
+
+	heading := MeshHeading{Descriptor: term(parts[0])}
+	for _, qualifier := range parts[1:] {
+		heading.Qualifiers = append(heading.Qualifiers, term(qualifier))
+	}
+	return heading
+}
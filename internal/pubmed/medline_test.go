@@ -0,0 +1,80 @@
+package pubmed
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMedline(t *testing.T) {
+	f, err := os.Open("testdata/medline.nbib")
+	require.NoError(t, err)
+	defer f.Close()
+
+	citations, err := ParseMedline(f)
+	require.NoError(t, err)
+	require.Len(t, citations, 3)
+
+	c := citations[0]
+	assert.Equal(t, "31234567", c.PMID)
+	assert.Equal(t, "Ibuprofen versus paracetamol for acute postoperative pain: a randomised trial.", c.Title)
+	assert.Equal(t, "BACKGROUND: Postoperative pain is common after day surgery. METHODS: Adults were randomised to ibuprofen 400 mg or paracetamol 1 g.", c.AbstractText())
+	assert.Equal(t, "British journal of clinical pharmacology", c.Journal)
+	assert.Equal(t, "Br J Clin Pharmacol", c.JournalAbbr)
+	assert.Equal(t, "86", c.Volume)
+	assert.Equal(t, "4", c.Issue)
+	assert.Equal(t, "712-720", c.Pages)
+	assert.Equal(t, PubDate{Year: 2020, Month: "Apr", Day: "15"}, c.PubDate)
+	assert.Equal(t, "10.1111/bcp.14180", c.DOI)
+
+	assert.Equal(t, []Author{
+		{
+			LastName: "Smith", ForeName: "Jane A", Initials: "JA",
+			Affiliations: []string{"Department of Anaesthesia, Royal Infirmary, Edinburgh, UK.", "University of Edinburgh, Edinburgh, UK."},
+		},
+		{LastName: "van der Berg", ForeName: "Karl", Initials: "K"},
+		{CollectiveName: "POP Trial Investigators"},
+	}, c.Authors)
+
+	assert.Equal(t, []MeshHeading{
+		{Descriptor: MeshTerm{Name: "Analgesics, Non-Narcotic"}, Qualifiers: []MeshTerm{{Name: "therapeutic use", MajorTopic: true}}},
+		{Descriptor: MeshTerm{Name: "Ibuprofen", MajorTopic: true}, Qualifiers: []MeshTerm{{Name: "administration & dosage"}, {Name: "therapeutic use"}}},
+		{Descriptor: MeshTerm{Name: "Pain, Postoperative"}},
+	}, c.MeshHeadings)
+
+	old := citations[1]
+	assert.Equal(t, PubDate{Year: 1998, MedlineDate: "1998 Dec-1999 Jan"}, old.PubDate)
+	assert.Equal(t, []string{"Dupont M", "Martin"}, old.Article().Authors)
+	assert.Equal(t, "Pain Med Q", old.Article().Journal)
+
+	seasonal := citations[2]
+	assert.Equal(t, PubDate{Year: 2019, Season: "Spring"}, seasonal.PubDate)
+	assert.Equal(t, "Ibuprofène et douleur.", seasonal.Title)
+	assert.Equal(t, "Journal of Seasonal Studies", seasonal.Article().Journal)
+}
+
+func TestMedlineReader_Line(t *testing.T) {
+	m := NewMedlineReader(strings.NewReader("\nPMID- 1\nTI  - One\n\n\nPMID- 2\r\nTI  - Two\r\n"))
+
+	for _, want := range []struct {
+		pmid string
+		line int
+	}{{"1", 2}, {"2", 6}} {
+		c, err := m.Next()
+		require.NoError(t, err)
+		assert.Equal(t, want.pmid, c.PMID)
+		assert.Equal(t, want.line, m.Line())
+	}
+
+	_, err := m.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParseMedline_Malformed(t *testing.T) {
+	_, err := ParseMedline(strings.NewReader("PMID- 1\nnot a field\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
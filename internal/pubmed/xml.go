@@ -0,0 +1,311 @@
+// Package pubmed reads the citation formats published by NLM for PubMed
+package pubmed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Decoder reads citations from a PubmedArticleSet XML document (efetch
+// output or a baseline/update file) one PubmedArticle at a time, so memory
+// use does not grow with the size of the document.
+type Decoder struct {
+	xml *xml.Decoder
+	// line is the line the last record started on
+	line     int
+	onDelete func(pmids []string) error
+}
+
+// NewDecoder returns a decoder reading PubMed XML from r
+func NewDecoder(r io.Reader) *Decoder {
+	d := xml.NewDecoder(r)
+	// Titles and abstracts occasionally contain HTML entities
+	d.Entity = xml.HTMLEntity
+	return &Decoder{xml: d}
+}
+
+// OnDelete registers fn to be called with the PMIDs of each DeleteCitation
+// block, as found in update files. Next calls it when it reaches the block,
+// so deletions are seen in document order relative to citations; an error
+// from fn is returned by Next. Deletions are ignored if fn is not set.
+func (d *Decoder) OnDelete(fn func(pmids []string) error) {
+	d.onDelete = fn
+}
+
+// Next returns the next citation in the document, or io.EOF at its end.
+// Elements other than PubmedArticle and DeleteCitation, such as
+// PubmedBookArticle, are skipped.
+func (d *Decoder) Next() (*Citation, error) {
+	for {
+		token, err := d.xml.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PubMed XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "PubmedArticle":
+		case "DeleteCitation":
+			if err := d.deleteCitation(&start); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			continue
+		}
+
+		d.line, _ = d.xml.InputPos()
+
+		var article xmlPubmedArticle
+		if err := d.xml.DecodeElement(&article, &start); err != nil {
+			return nil, fmt.Errorf("failed to decode PubmedArticle: %w", err)
+		}
+		return article.citation(), nil
+	}
+}
+
+func (d *Decoder) deleteCitation(start *xml.StartElement) error {
+	line, _ := d.xml.InputPos()
+
+	var deletion struct {
+		PMIDs []string `xml:"PMID"`
+	}
+	if err := d.xml.DecodeElement(&deletion, start); err != nil {
+		return fmt.Errorf("failed to decode DeleteCitation: %w", err)
+	}
+	if d.onDelete == nil {
+		return nil
+	}
+
+	pmids := make([]string, 0, len(deletion.PMIDs))
+	for _, pmid := range deletion.PMIDs {
+		if pmid = strings.TrimSpace(pmid); pmid != "" {
+			pmids = append(pmids, pmid)
+		}
+	}
+
+	d.line = line
+	return d.onDelete(pmids)
+}
+
+// Line returns the line the last citation returned by Next, or the last
+// DeleteCitation block passed to the OnDelete callback, started on
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+// ParseArticleSet reads every citation in a PubmedArticleSet document
+func ParseArticleSet(r io.Reader) ([]*Citation, error) {
+	d := NewDecoder(r)
+
+	var citations []*Citation
+	for {
+		citation, err := d.Next()
+		if err == io.EOF {
+			return citations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		citations = append(citations, citation)
+	}
+}
+
+// text is the character data of an element and all of its children, which
+// keeps the words inside inline markup such as <i> and <sup>
+type text string
+
+func (t *text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := token.(type) {
+		case xml.CharData:
+			b.Write(tok)
+		case xml.EndElement:
+			if tok.Name == start.Name {
+				*t = text(strings.Join(strings.Fields(b.String()), " "))
+				return nil
+			}
+		}
+	}
+}
+
+// The xml* types mirror the parts of the PubMed DTD the parser reads
+
+type xmlPubmedArticle struct {
+	Citation struct {
+		PMID    string `xml:"PMID"`
+		Article struct {
+			Journal struct {
+				Title           string `xml:"Title"`
+				ISOAbbreviation string `xml:"ISOAbbreviation"`
+				Issue           struct {
+					Volume  string     `xml:"Volume"`
+					Issue   string     `xml:"Issue"`
+					PubDate xmlPubDate `xml:"PubDate"`
+				} `xml:"JournalIssue"`
+			} `xml:"Journal"`
+			Title           text   `xml:"ArticleTitle"`
+			VernacularTitle text   `xml:"VernacularTitle"`
+			Pagination      string `xml:"Pagination>MedlinePgn"`
+			ELocationIDs    []struct {
+				Type  string `xml:"EIdType,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"ELocationID"`
+			Abstract []xmlAbstractText `xml:"Abstract>AbstractText"`
+			Authors  []struct {
+				LastName       string `xml:"LastName"`
+				ForeName       string `xml:"ForeName"`
+				Initials       string `xml:"Initials"`
+				CollectiveName text   `xml:"CollectiveName"`
+				Affiliations   []text `xml:"AffiliationInfo>Affiliation"`
+			} `xml:"AuthorList>Author"`
+		} `xml:"Article"`
+		MedlineTA    string `xml:"MedlineJournalInfo>MedlineTA"`
+		MeshHeadings []struct {
+			Descriptor xmlMeshName   `xml:"DescriptorName"`
+			Qualifiers []xmlMeshName `xml:"QualifierName"`
+		} `xml:"MeshHeadingList>MeshHeading"`
+	} `xml:"MedlineCitation"`
+	ArticleIDs []struct {
+		Type  string `xml:"IdType,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"PubmedData>ArticleIdList>ArticleId"`
+}
+
+type xmlAbstractText struct {
+	Label    string
+	Category string
+	Text     text
+}
+
+func (a *xmlAbstractText) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "Label":
+			a.Label = attr.Value
+		case "NlmCategory":
+			a.Category = attr.Value
+		}
+	}
+	return a.Text.UnmarshalXML(d, start)
+}
+
+type xmlPubDate struct {
+	Year        string `xml:"Year"`
+	Month       string `xml:"Month"`
+	Day         string `xml:"Day"`
+	Season      string `xml:"Season"`
+	MedlineDate string `xml:"MedlineDate"`
+}
+
+type xmlMeshName struct {
+	UI         string `xml:"UI,attr"`
+	MajorTopic string `xml:"MajorTopicYN,attr"`
+	Name       string `xml:",chardata"`
+}
+
+func (m xmlMeshName) term() MeshTerm {
+	return MeshTerm{UI: m.UI, Name: strings.TrimSpace(m.Name), MajorTopic: m.MajorTopic == "Y"}
+}
+
+func (a *xmlPubmedArticle) citation() *Citation {
+	mc := &a.Citation
+	art := &mc.Article
+
+	c := &Citation{
+		PMID:         strings.TrimSpace(mc.PMID),
+		Title:        string(art.Title),
+		Journal:      strings.TrimSpace(art.Journal.Title),
+		JournalAbbr:  strings.TrimSpace(mc.MedlineTA),
+		Volume:       strings.TrimSpace(art.Journal.Issue.Volume),
+		Issue:        strings.TrimSpace(art.Journal.Issue.Issue),
+		Pages:        strings.TrimSpace(art.Pagination),
+		PubDate:      art.Journal.Issue.PubDate.pubDate(),
+		Abstract:     make([]AbstractSection, 0, len(art.Abstract)),
+		Authors:      make([]Author, 0, len(art.Authors)),
+		MeshHeadings: make([]MeshHeading, 0, len(mc.MeshHeadings)),
+	}
+
+	// Some non-English articles only have a title in their own language
+	if c.Title == "" {
+		c.Title = string(art.VernacularTitle)
+	}
+	if c.JournalAbbr == "" {
+		c.JournalAbbr = strings.TrimSpace(art.Journal.ISOAbbreviation)
+	}
+
+	for _, section := range art.Abstract {
+		c.Abstract = append(c.Abstract, AbstractSection{
+			Label:    section.Label,
+			Category: section.Category,
+			Text:     string(section.Text),
+		})
+	}
+
+	for _, author := range art.Authors {
+		au := Author{
+			LastName:       strings.TrimSpace(author.LastName),
+			ForeName:       strings.TrimSpace(author.ForeName),
+			Initials:       strings.TrimSpace(author.Initials),
+			CollectiveName: string(author.CollectiveName),
+		}
+		for _, affiliation := range author.Affiliations {
+			au.Affiliations = append(au.Affiliations, string(affiliation))
+		}
+		c.Authors = append(c.Authors, au)
+	}
+
+	for _, heading := range mc.MeshHeadings {
+		h := MeshHeading{Descriptor: heading.Descriptor.term()}
+		for _, qualifier := range heading.Qualifiers {
+			h.Qualifiers = append(h.Qualifiers, qualifier.term())
+		}
+		c.MeshHeadings = append(c.MeshHeadings, h)
+	}
+
+	// The DOI is usually in ArticleIdList, sometimes only in ELocationID
+	for _, id := range a.ArticleIDs {
+		if id.Type == "doi" {
+			c.DOI = strings.TrimSpace(id.Value)
+			break
+		}
+	}
+	if c.DOI == "" {
+		for _, id := range art.ELocationIDs {
+			if id.Type == "doi" {
+				c.DOI = strings.TrimSpace(id.Value)
+				break
+			}
+		}
+	}
+
+	return c
+}
+
+func (d xmlPubDate) pubDate() PubDate {
+	date := PubDate{
+		Month:       strings.TrimSpace(d.Month),
+		Day:         strings.TrimSpace(d.Day),
+		Season:      strings.TrimSpace(d.Season),
+		MedlineDate: strings.TrimSpace(d.MedlineDate),
+	}
+	date.Year = parseYear(d.Year)
+	if date.Year == 0 {
+		date.Year = parseYear(d.MedlineDate)
+	}
+	return date
+}
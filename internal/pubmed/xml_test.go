@@ -0,0 +1,158 @@
+package pubmed
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"pubmed-api/internal/domain"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFixture(t *testing.T, name string) []*Citation {
+	t.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	citations, err := ParseArticleSet(f)
+	require.NoError(t, err)
+	return citations
+}
+
+func TestParseArticleSet(t *testing.T) {
+	citations := parseFixture(t, "pubmed_articles.xml")
+	require.Len(t, citations, 3, "book articles are skipped")
+
+	c := citations[0]
+	assert.Equal(t, "31234567", c.PMID)
+	assert.Equal(t, "Ibuprofen versus paracetamol for acute postoperative pain: a randomised trial.", c.Title)
+	assert.Equal(t, "British journal of clinical pharmacology", c.Journal)
+	assert.Equal(t, "Br J Clin Pharmacol", c.JournalAbbr)
+	assert.Equal(t, "86", c.Volume)
+	assert.Equal(t, "4", c.Issue)
+	assert.Equal(t, "712-720", c.Pages)
+	assert.Equal(t, PubDate{Year: 2020, Month: "Apr", Day: "15"}, c.PubDate)
+	assert.Equal(t, "10.1111/bcp.14180", c.DOI)
+
+	require.Len(t, c.Abstract, 4)
+	assert.Equal(t, AbstractSection{Label: "METHODS", Category: "METHODS", Text: "Adults were randomised to ibuprofen 400 mg or paracetamol 1 g."}, c.Abstract[1])
+	assert.Equal(t, "Pain scores at 6 h were lower with ibuprofen (P<0.01).", c.Abstract[2].Text)
+
+	require.Len(t, c.Authors, 3)
+	assert.Equal(t, Author{
+		LastName: "Smith", ForeName: "Jane A", Initials: "JA",
+		Affiliations: []string{"Department of Anaesthesia, Royal Infirmary, Edinburgh, UK.", "University of Edinburgh, Edinburgh, UK."},
+	}, c.Authors[0])
+	assert.Equal(t, "Müller K", c.Authors[1].Name())
+	assert.Equal(t, "POP Trial Investigators", c.Authors[2].Name())
+
+	require.Len(t, c.MeshHeadings, 3)
+	assert.Equal(t, MeshHeading{
+		Descriptor: MeshTerm{UI: "D007052", Name: "Ibuprofen", MajorTopic: true},
+		Qualifiers: []MeshTerm{
+			{UI: "Q000008", Name: "administration & dosage"},
+			{UI: "Q000627", Name: "therapeutic use"},
+		},
+	}, c.MeshHeadings[1])
+	assert.Empty(t, c.MeshHeadings[2].Qualifiers)
+}
+
+func TestParseArticleSet_DateVariants(t *testing.T) {
+	citations := parseFixture(t, "pubmed_articles.xml")
+	require.Len(t, citations, 3)
+
+	medline := citations[1]
+	assert.Equal(t, PubDate{Year: 1998, MedlineDate: "1998 Dec-1999 Jan"}, medline.PubDate)
+	assert.Equal(t, "Pain Med Q", medline.JournalAbbr, "falls back to ISOAbbreviation")
+	assert.Equal(t, "10.1000/pmq.1998.045", medline.DOI, "falls back to ELocationID")
+	assert.Equal(t, "An unstructured abstract about fever spanning several lines.", medline.AbstractText())
+
+	seasonal := citations[2]
+	assert.Equal(t, PubDate{Year: 2019, Season: "Spring"}, seasonal.PubDate)
+	assert.Equal(t, "Ibuprofène et douleur.", seasonal.Title, "falls back to VernacularTitle")
+	assert.Empty(t, seasonal.Abstract)
+	assert.Empty(t, seasonal.DOI)
+}
+
+func TestCitation_Article(t *testing.T) {
+	citations := parseFixture(t, "pubmed_articles.xml")
+	require.NotEmpty(t, citations)
+
+	assert.Equal(t, &domain.Article{
+		PMID:  "31234567",
+		Title: "Ibuprofen versus paracetamol for acute postoperative pain: a randomised trial.",
+		Abstract: "BACKGROUND: Postoperative pain is common after day surgery.\n" +
+			"METHODS: Adults were randomised to ibuprofen 400 mg or paracetamol 1 g.\n" +
+			"RESULTS: Pain scores at 6 h were lower with ibuprofen (P<0.01).\n" +
+			"CONCLUSIONS: Ibuprofen is an effective first-line analgesic.",
+		Authors:   []string{"Smith JA", "Müller K", "POP Trial Investigators"},
+		Journal:   "Br J Clin Pharmacol",
+		PubYear:   2020,
+		MeshTerms: []string{"Analgesics, Non-Narcotic", "Ibuprofen", "Pain, Postoperative"},
+		DOI:       "10.1111/bcp.14180",
+	}, citations[0].Article())
+
+	assert.Equal(t, "Journal of Seasonal Studies", citations[2].Article().Journal, "falls back to the full title")
+}
+
+func TestDecoder_Next(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<PubmedArticleSet>
+		<PubmedArticle><MedlineCitation><PMID>1</PMID></MedlineCitation></PubmedArticle>
+		<PubmedArticle><MedlineCitation><PMID>2</PMID></MedlineCitation></PubmedArticle>
+	</PubmedArticleSet>`))
+
+	for i, want := range []string{"1", "2"} {
+		c, err := d.Next()
+		require.NoError(t, err)
+		assert.Equal(t, want, c.PMID)
+		assert.Equal(t, i+2, d.Line())
+	}
+
+	_, err := d.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestParseArticleSet_Malformed(t *testing.T) {
+	_, err := ParseArticleSet(strings.NewReader(`<PubmedArticleSet><PubmedArticle><MedlineCitation>`))
+	assert.Error(t, err)
+}
+
+func TestDecoder_OnDelete(t *testing.T) {
+	f, err := os.Open("testdata/pubmed_update.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []string
+	d := NewDecoder(f)
+	d.OnDelete(func(pmids []string) error {
+		events = append(events, fmt.Sprintf("delete %s at line %d", strings.Join(pmids, ","), d.Line()))
+		return nil
+	})
+
+	for {
+		c, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, "upsert "+c.PMID)
+	}
+
+	assert.Equal(t, []string{"upsert 31234567", "delete 9876543,55555555 at line 27", "upsert 9876543"}, events)
+}
+
+func TestDecoder_OnDeleteError(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`<PubmedArticleSet><DeleteCitation><PMID>1</PMID></DeleteCitation></PubmedArticleSet>`))
+	d.OnDelete(func(pmids []string) error {
+		return errors.New("storage failed")
+	})
+
+	_, err := d.Next()
+	assert.ErrorContains(t, err, "storage failed")
+}
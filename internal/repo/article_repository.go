@@ -10,11 +10,18 @@ type ArticleRepository interface {
 	// FindByID retrieves an article by its PubMed ID
 	FindByID(ctx context.Context, pmid string) (*domain.Article, error)
 
+	// FindByIDs retrieves the articles with the given PubMed IDs in a single
+	// query. PMIDs that match no article are left out and the order of the
+	// articles is unspecified.
+	FindByIDs(ctx context.Context, pmids []string) ([]*domain.Article, error)
+
 	// Search performs a search with filters and pagination
 	Search(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error)
 
-	// GetStats returns aggregate statistics
-	GetStats(ctx context.Context) (*domain.Stats, error)
+	// GetStats returns aggregate statistics over the articles matching the
+	// filters, with at most topJournals journals. Pagination, sort and facet
+	// fields of filters are ignored.
+	GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error)
 
 	// LoadData loads articles from a data source (file, S3, etc.)
 	LoadData(ctx context.Context, dataPath string) error
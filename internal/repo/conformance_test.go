@@ -0,0 +1,842 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"pubmed-api/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repositoryFactory opens an empty repository seeded with articles, or skips
+// the test when the backend is unavailable
+type repositoryFactory func(t *testing.T, articles ...*domain.Article) ArticleRepository
+
+// articleWriter and loadHistory are the optional interfaces the platform
+// loader detects on a repository
+type articleWriter interface {
+	InsertArticles(ctx context.Context, articles []*domain.Article) (int, error)
+	DeleteArticles(ctx context.Context, pmids []string) (int, error)
+}
+
+type loadHistory interface {
+	FindLoad(ctx context.Context, source string) (*domain.LoadRecord, error)
+	RecordLoad(ctx context.Context, record *domain.LoadRecord) error
+}
+
+// testArticleRepository runs the conformance suite every ArticleRepository
+// implementation must pass, so that backends behave identically. Writer and
+// load history tests run when the repository implements those interfaces.
+func testArticleRepository(t *testing.T, newRepo repositoryFactory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newRepo repositoryFactory)
+	}{
+		{name: "FindByID", test: testFindByID},
+		{name: "FindByIDs", test: testFindByIDs},
+		{name: "SearchFullText", test: testSearchFullText},
+		{name: "SearchUnicode", test: testSearchUnicode},
+		{name: "SearchRelevance", test: testSearchRelevance},
+		{name: "SearchQueryExpr", test: testSearchQueryExpr},
+		{name: "SearchFilters", test: testSearchFilters},
+		{name: "SearchMesh", test: testSearchMesh},
+		{name: "SearchFacets", test: testSearchFacets},
+		{name: "SearchPagination", test: testSearchPagination},
+		{name: "SearchSortStability", test: testSearchSortStability},
+		{name: "SearchCursor", test: testSearchCursor},
+		{name: "GetStats", test: testGetStats},
+		{name: "InsertArticles", test: testInsertArticles},
+		{name: "DeleteArticles", test: testDeleteArticles},
+		{name: "LoadHistory", test: testLoadHistory},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.test(t, newRepo) })
+	}
+}
+
+func searchPMIDs(t *testing.T, r ArticleRepository, filters *domain.SearchFilters) []string {
+	t.Helper()
+
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 50
+	}
+
+	result, err := r.Search(context.Background(), filters)
+	require.NoError(t, err)
+
+	pmids := []string{}
+	for _, article := range result.Items {
+		pmids = append(pmids, article.PMID)
+	}
+	return pmids
+}
+
+func testFindByID(t *testing.T, newRepo repositoryFactory) {
+	article := &domain.Article{
+		PMID:      "31452104",
+		Title:     "Ibuprofen and renal outcomes: a cohort study",
+		Abstract:  "Patients took ibuprofen daily.",
+		Authors:   []string{"Smith J", "Lee K", "Brown M"},
+		Journal:   "J Clin Pharm",
+		PubYear:   2019,
+		MeshTerms: []string{"Ibuprofen", "Kidney Diseases", "Cohort Studies"},
+		DOI:       "10.1000/xyz123",
+	}
+	r := newRepo(t, article,
+		&domain.Article{PMID: "2", Title: "Aspirin", Journal: "J1", PubYear: 2020},
+	)
+	ctx := context.Background()
+
+	// Every field round-trips, with authors and MeSH terms in order
+	found, err := r.FindByID(ctx, "31452104")
+	require.NoError(t, err)
+	assert.Equal(t, article, found)
+
+	found, err = r.FindByID(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, "", found.Abstract)
+	assert.Empty(t, found.Authors)
+	assert.Empty(t, found.MeshTerms)
+
+	for _, pmid := range []string{"404", "", "3145210", "31452104 "} {
+		_, err := r.FindByID(ctx, pmid)
+		assert.ErrorContains(t, err, "article not found", "pmid %q", pmid)
+	}
+}
+
+func testFindByIDs(t *testing.T, newRepo repositoryFactory) {
+	article := &domain.Article{
+		PMID:      "31452104",
+		Title:     "Ibuprofen and renal outcomes: a cohort study",
+		Abstract:  "Patients took ibuprofen daily.",
+		Authors:   []string{"Smith J", "Lee K", "Brown M"},
+		Journal:   "J Clin Pharm",
+		PubYear:   2019,
+		MeshTerms: []string{"Ibuprofen", "Kidney Diseases", "Cohort Studies"},
+		DOI:       "10.1000/xyz123",
+	}
+	r := newRepo(t, article,
+		&domain.Article{PMID: "2", Title: "Aspirin", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "3", Title: "Paracetamol", Journal: "J2", PubYear: 2021},
+	)
+	ctx := context.Background()
+
+	found, err := r.FindByIDs(ctx, []string{"3", "404", "31452104", "", "3145210"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+
+	byPMID := map[string]*domain.Article{}
+	for _, a := range found {
+		byPMID[a.PMID] = a
+	}
+	assert.Equal(t, article, byPMID["31452104"])
+	assert.Equal(t, "Paracetamol", byPMID["3"].Title)
+
+	// Repeated PMIDs find their article once
+	found, err = r.FindByIDs(ctx, []string{"2", "2"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "2", found[0].PMID)
+
+	found, err = r.FindByIDs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// A large batch is still one query
+	pmids := make([]string, 5000)
+	for i := range pmids {
+		pmids[i] = fmt.Sprintf("%d", 100000+i)
+	}
+	pmids[4999] = "2"
+	found, err = r.FindByIDs(ctx, pmids)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "2", found[0].PMID)
+}
+
+func testSearchFullText(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen for postoperative pain", Abstract: "Pain scores fell.", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "2", Title: "Healthcare costs in Spain", Abstract: "A Spanish cohort.", Journal: "J2", PubYear: 2021},
+		&domain.Article{PMID: "3", Title: "Fever management", Abstract: "Paracetamol and ibuprofen in children with fever.", Journal: "J1", PubYear: 2022},
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "whole words only", query: "pain", want: []string{"1"}},
+		{name: "case insensitive", query: "IBUPROFEN", want: []string{"1", "3"}},
+		{name: "all words required", query: "ibuprofen fever", want: []string{"3"}},
+		{name: "prefix", query: "spa*", want: []string{"2"}},
+		{name: "punctuation between words", query: "post-operative", want: []string{}},
+		{name: "fts syntax is literal", query: `fever" OR "pain`, want: []string{}},
+		{name: "tsquery syntax is literal", query: `fever' | 'pain`, want: []string{}},
+		{name: "punctuation only", query: "!!!", want: []string{}},
+		{name: "no match", query: "warfarin", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, &domain.SearchFilters{Query: tt.query}))
+		})
+	}
+}
+
+func testSearchUnicode(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Étude de cohorte à Genève", Authors: []string{"Müller H"}, Journal: "Revue Médicale Suisse", PubYear: 2020, MeshTerms: []string{"Suisse"}},
+		&domain.Article{PMID: "2", Title: "Ärzte und Schmerztherapie", Authors: []string{"Öztürk A"}, Journal: "Der Schmerz", PubYear: 2021},
+		&domain.Article{PMID: "3", Title: "Naïve Bayes classifiers for triage", Authors: []string{"García-López M"}, Journal: "J1", PubYear: 2022},
+		&domain.Article{PMID: "4", Title: "Θεραπεία της σχιζοφρένειας", Abstract: "統合失調症の治療", Journal: "J1", PubYear: 2022},
+	)
+
+	tests := []struct {
+		name    string
+		filters *domain.SearchFilters
+		want    []string
+	}{
+		{name: "accented query", filters: &domain.SearchFilters{Query: "genève"}, want: []string{"1"}},
+		{name: "diacritics ignored", filters: &domain.SearchFilters{Query: "geneve etude"}, want: []string{"1"}},
+		{name: "accented query against plain text", filters: &domain.SearchFilters{Query: "triagé"}, want: []string{"3"}},
+		{name: "non-ASCII case folding", filters: &domain.SearchFilters{Query: "ÄRZTE"}, want: []string{"2"}},
+		{name: "diaeresis", filters: &domain.SearchFilters{Query: "naive"}, want: []string{"3"}},
+		{name: "accented prefix", filters: &domain.SearchFilters{Query: "schmerz*"}, want: []string{"2"}},
+		{name: "non-Latin script", filters: &domain.SearchFilters{Query: "σχιζοφρένειας"}, want: []string{"4"}},
+		{name: "author", filters: &domain.SearchFilters{Authors: []string{"Müller"}}, want: []string{"1"}},
+		{name: "hyphenated author", filters: &domain.SearchFilters{Authors: []string{"García-López"}}, want: []string{"3"}},
+		{name: "journal", filters: &domain.SearchFilters{Journals: []string{"Revue Médicale Suisse"}}, want: []string{"1"}},
+		{name: "journal field", filters: &domain.SearchFilters{Expr: &domain.QueryTerm{Text: "revue médicale suisse", Field: domain.FieldJournal}}, want: []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, tt.filters))
+		})
+	}
+
+	// Stored text keeps its diacritics
+	result, err := r.Search(context.Background(), &domain.SearchFilters{
+		Query: "geneve", Page: 1, PageSize: 10, Facets: []string{domain.FacetJournal, domain.FacetAuthor},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "Étude de cohorte à Genève", result.Items[0].Title)
+	assert.Equal(t, map[string][]domain.FacetCount{
+		domain.FacetJournal: {{Value: "Revue Médicale Suisse", Count: 1}},
+		domain.FacetAuthor:  {{Value: "Müller H", Count: 1}},
+	}, result.Facets)
+}
+
+func testSearchRelevance(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Renal outcomes", Abstract: "Patients took ibuprofen daily.", Journal: "J Clin Pharm", PubYear: 2020},
+		&domain.Article{PMID: "2", Title: "Ibuprofen and renal outcomes", Abstract: "A cohort study.", Journal: "J Clin Pharm", PubYear: 2020},
+		&domain.Article{PMID: "3", Title: "Aspirin", Abstract: "No NSAIDs here.", Journal: "J1", PubYear: 2020},
+	)
+
+	result, err := r.Search(context.Background(), &domain.SearchFilters{
+		Query: "ibuprofen", Sort: "relevance", Page: 1, PageSize: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+
+	// The title match outranks the abstract match
+	assert.Equal(t, "2", result.Items[0].PMID)
+	assert.Equal(t, "1", result.Items[1].PMID)
+	assert.Greater(t, result.Items[0].Score, result.Items[1].Score)
+	assert.Greater(t, result.Items[1].Score, 0.0)
+
+	// Without a query nothing is scored and relevance is PMID order
+	result, err = r.Search(context.Background(), &domain.SearchFilters{Sort: "relevance", Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 3)
+	for _, hit := range result.Items {
+		assert.Zero(t, hit.Score)
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, searchPMIDs(t, r, &domain.SearchFilters{Sort: "relevance"}))
+
+	// Mixed queries are still ranked by their full-text terms
+	result, err = r.Search(context.Background(), &domain.SearchFilters{
+		Expr: &domain.QueryAnd{Operands: []domain.QueryNode{
+			&domain.QueryTerm{Text: "ibuprofen", Field: domain.FieldTitleAbstract},
+			&domain.QueryTerm{Text: "J Clin Pharm", Field: domain.FieldJournal},
+		}},
+		Sort: "relevance", Page: 1, PageSize: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "2", result.Items[0].PMID)
+	assert.Greater(t, result.Items[0].Score, result.Items[1].Score)
+}
+
+func testSearchQueryExpr(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen for pain", Abstract: "Adults only.", Authors: []string{"Smith J", "Lee K"}, Journal: "J Clin Pharm", PubYear: 2019},
+		&domain.Article{PMID: "2", Title: "Ibuprofen for fever", Abstract: "A pediatric trial.", Authors: []string{"Brown M"}, Journal: "Pediatrics", PubYear: 2021},
+		&domain.Article{PMID: "3", Title: "Paracetamol for fever", Abstract: "Low dose ibuprofen as control.", Authors: []string{"Smithers A"}, Journal: "J Clin Pharm", PubYear: 2022},
+	)
+
+	term := func(text string, field domain.QueryField) *domain.QueryTerm {
+		return &domain.QueryTerm{Text: text, Field: field}
+	}
+
+	tests := []struct {
+		name string
+		expr domain.QueryNode
+		want []string
+	}{
+		{
+			// ibuprofen AND (pain OR fever) NOT pediatric
+			name: "boolean full-text",
+			expr: &domain.QueryNot{
+				Include: &domain.QueryAnd{Operands: []domain.QueryNode{
+					term("ibuprofen", domain.FieldTitleAbstract),
+					&domain.QueryOr{Operands: []domain.QueryNode{
+						term("pain", domain.FieldTitleAbstract),
+						term("fever", domain.FieldTitleAbstract),
+					}},
+				}},
+				Exclude: term("pediatric", domain.FieldTitleAbstract),
+			},
+			want: []string{"1", "3"},
+		},
+		{name: "title field", expr: term("ibuprofen", domain.FieldTitle), want: []string{"1", "2"}},
+		{name: "abstract field", expr: term("ibuprofen", domain.FieldAbstract), want: []string{"3"}},
+		{name: "prefix term", expr: &domain.QueryTerm{Text: "paracet", Field: domain.FieldAll, Prefix: true}, want: []string{"3"}},
+		{name: "phrase", expr: term("low dose ibuprofen", domain.FieldAbstract), want: []string{"3"}},
+		{name: "phrase words out of order", expr: term("ibuprofen dose", domain.FieldAbstract), want: []string{}},
+		{name: "author", expr: term("Smith", domain.FieldAuthor), want: []string{"1", "3"}},
+		{name: "journal ignores case", expr: term("j clin pharm", domain.FieldJournal), want: []string{"1", "3"}},
+		{name: "journal is exact", expr: term("J Clin", domain.FieldJournal), want: []string{}},
+		{name: "years", expr: &domain.QueryYears{From: 2020, To: 2022}, want: []string{"2", "3"}},
+		{
+			// fever[ti] NOT Brown[au]
+			name: "mixed fields",
+			expr: &domain.QueryNot{
+				Include: term("fever", domain.FieldTitle),
+				Exclude: term("Brown", domain.FieldAuthor),
+			},
+			want: []string{"3"},
+		},
+		{
+			// pain[ti] OR 2022[dp]
+			name: "or across fields",
+			expr: &domain.QueryOr{Operands: []domain.QueryNode{
+				term("pain", domain.FieldTitle),
+				&domain.QueryYears{From: 2022, To: 2022},
+			}},
+			want: []string{"1", "3"},
+		},
+		{
+			// Operators inside a term are literal text
+			name: "quoted syntax",
+			expr: term(`fever" OR "pain`, domain.FieldTitleAbstract),
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, &domain.SearchFilters{Query: "ignored", Expr: tt.expr}))
+		})
+	}
+}
+
+func testSearchFilters(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen A", Authors: []string{"Smith J", "Lee K"}, Journal: "J Clin Pharm", PubYear: 2017},
+		&domain.Article{PMID: "2", Title: "Ibuprofen B", Authors: []string{"Smith J"}, Journal: "Pediatrics", PubYear: 2019},
+		&domain.Article{PMID: "3", Title: "Aspirin C", Authors: []string{"Lee K", "Brown M"}, Journal: "Pain Medicine", PubYear: 2020},
+		&domain.Article{PMID: "4", Title: "Aspirin D", Authors: []string{"Brown M"}, Journal: "J Clin Pharm", PubYear: 2022},
+	)
+	year := func(y int) *int { return &y }
+
+	tests := []struct {
+		name    string
+		filters *domain.SearchFilters
+		want    []string
+	}{
+		{name: "year from", filters: &domain.SearchFilters{YearFrom: year(2020)}, want: []string{"3", "4"}},
+		{name: "year to", filters: &domain.SearchFilters{YearTo: year(2019)}, want: []string{"1", "2"}},
+		{name: "year range", filters: &domain.SearchFilters{YearFrom: year(2019), YearTo: year(2020)}, want: []string{"2", "3"}},
+		{name: "single year", filters: &domain.SearchFilters{YearFrom: year(2022), YearTo: year(2022)}, want: []string{"4"}},
+		{name: "empty year range", filters: &domain.SearchFilters{YearFrom: year(2021), YearTo: year(2021)}, want: []string{}},
+		{name: "journals", filters: &domain.SearchFilters{Journals: []string{"Pediatrics", "Pain Medicine"}}, want: []string{"2", "3"}},
+		{name: "unknown journal", filters: &domain.SearchFilters{Journals: []string{"Nature"}}, want: []string{}},
+		{
+			name:    "all authors",
+			filters: &domain.SearchFilters{Authors: []string{"Smith", "Lee"}, AuthorOperator: domain.OperatorAnd},
+			want:    []string{"1"},
+		},
+		{
+			name:    "any author",
+			filters: &domain.SearchFilters{Authors: []string{"Smith", "Lee"}, AuthorOperator: domain.OperatorOr},
+			want:    []string{"1", "2", "3"},
+		},
+		{name: "author ignores ASCII case", filters: &domain.SearchFilters{Authors: []string{"brown"}}, want: []string{"3", "4"}},
+		{
+			// A substring must fall within one author, not across the list
+			name:    "author boundaries",
+			filters: &domain.SearchFilters{Authors: []string{`J","Lee`}},
+			want:    []string{},
+		},
+		{
+			name:    "filters combine with full text",
+			filters: &domain.SearchFilters{Query: "ibuprofen", Journals: []string{"J Clin Pharm"}, YearFrom: year(2015)},
+			want:    []string{"1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, tt.filters))
+		})
+	}
+}
+
+func testSearchMesh(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen for pain", Journal: "J1", PubYear: 2020, MeshTerms: []string{"Ibuprofen", "Pain"}},
+		&domain.Article{PMID: "2", Title: "Postoperative care", Journal: "J1", PubYear: 2020, MeshTerms: []string{"Ibuprofen", "Pain, Postoperative"}},
+		&domain.Article{PMID: "3", Title: "Fever in children", Journal: "J1", PubYear: 2020, MeshTerms: []string{"Fever", "Anti-Inflammatory Agents"}},
+		&domain.Article{PMID: "4", Title: "No indexing yet", Journal: "J1", PubYear: 2020},
+	)
+
+	tests := []struct {
+		name    string
+		filters *domain.SearchFilters
+		want    []string
+	}{
+		{name: "exact term", filters: &domain.SearchFilters{Mesh: []string{"Pain"}}, want: []string{"1"}},
+		{name: "ignores case", filters: &domain.SearchFilters{Mesh: []string{"pain, postoperative"}}, want: []string{"2"}},
+		{name: "all terms", filters: &domain.SearchFilters{Mesh: []string{"Ibuprofen", "Pain"}, MeshOperator: domain.OperatorAnd}, want: []string{"1"}},
+		{name: "any term", filters: &domain.SearchFilters{Mesh: []string{"Pain", "Fever"}, MeshOperator: domain.OperatorOr}, want: []string{"1", "3"}},
+		{
+			name: "exploded term",
+			filters: &domain.SearchFilters{
+				Mesh:           []string{"Anti-Inflammatory Agents", "Pain, Postoperative"},
+				MeshExpansions: map[string][]string{"Anti-Inflammatory Agents": {"Ibuprofen"}},
+			},
+			want: []string{"2"},
+		},
+		{
+			name: "exploded any term",
+			filters: &domain.SearchFilters{
+				Mesh:           []string{"Anti-Inflammatory Agents"},
+				MeshOperator:   domain.OperatorOr,
+				MeshExpansions: map[string][]string{"Anti-Inflammatory Agents": {"Ibuprofen"}},
+			},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name:    "mh field tag",
+			filters: &domain.SearchFilters{Expr: &domain.QueryTerm{Text: "anti-inflammatory agents", Field: domain.FieldMesh}},
+			want:    []string{"3"},
+		},
+		{
+			name:    "full text excludes mesh by default",
+			filters: &domain.SearchFilters{Query: "ibuprofen"},
+			want:    []string{"1"},
+		},
+		{
+			name:    "full text includes mesh on request",
+			filters: &domain.SearchFilters{Query: "ibuprofen", IncludeMesh: true},
+			want:    []string{"1", "2"},
+		},
+		{
+			name:    "articles without mesh index no terms",
+			filters: &domain.SearchFilters{Query: "null", IncludeMesh: true},
+			want:    []string{},
+		},
+		{
+			name:    "tagged terms ignore include mesh",
+			filters: &domain.SearchFilters{Expr: &domain.QueryTerm{Text: "ibuprofen", Field: domain.FieldTitleAbstract}, IncludeMesh: true},
+			want:    []string{"1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, tt.filters))
+		})
+	}
+}
+
+func testSearchFacets(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen for pain", Authors: []string{"Smith J", "Lee K"}, Journal: "J Clin Pharm", PubYear: 2020, MeshTerms: []string{"Ibuprofen", "Pain"}},
+		&domain.Article{PMID: "2", Title: "Ibuprofen for fever", Authors: []string{"Smith J"}, Journal: "Pediatrics", PubYear: 2021, MeshTerms: []string{"Ibuprofen", "Fever"}},
+		&domain.Article{PMID: "3", Title: "Ibuprofen dosing", Authors: []string{"Brown M"}, Journal: "J Clin Pharm", PubYear: 2020, MeshTerms: []string{"ibuprofen"}},
+		&domain.Article{PMID: "4", Title: "Aspirin for pain", Authors: []string{"Lee K"}, Journal: "Pain Medicine", PubYear: 2019, MeshTerms: []string{"Aspirin", "Pain"}},
+	)
+
+	result, err := r.Search(context.Background(), &domain.SearchFilters{
+		Query:    "ibuprofen",
+		Page:     1,
+		PageSize: 1,
+		Facets:   []string{domain.FacetJournal, domain.FacetYear, domain.FacetMesh, domain.FacetAuthor},
+	})
+	require.NoError(t, err)
+
+	// Facets cover every match, not just the returned page. MeSH terms are
+	// counted ignoring case.
+	assert.Len(t, result.Items, 1)
+	assert.Equal(t, map[string][]domain.FacetCount{
+		domain.FacetJournal: {{Value: "J Clin Pharm", Count: 2}, {Value: "Pediatrics", Count: 1}},
+		domain.FacetYear:    {{Value: "2020", Count: 2}, {Value: "2021", Count: 1}},
+		domain.FacetMesh:    {{Value: "Ibuprofen", Count: 3}, {Value: "Fever", Count: 1}, {Value: "Pain", Count: 1}},
+		domain.FacetAuthor:  {{Value: "Smith J", Count: 2}, {Value: "Brown M", Count: 1}, {Value: "Lee K", Count: 1}},
+	}, result.Facets)
+
+	// Facets of an empty result are empty
+	result, err = r.Search(context.Background(), &domain.SearchFilters{
+		Query: "warfarin", Page: 1, PageSize: 10, Facets: []string{domain.FacetJournal},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, result.Facets[domain.FacetJournal])
+
+	// Facets are opt-in
+	result, err = r.Search(context.Background(), &domain.SearchFilters{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Nil(t, result.Facets)
+}
+
+func testSearchPagination(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Aspirin one", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "2", Title: "Aspirin two", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "3", Title: "Aspirin three", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "4", Title: "Aspirin four", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "5", Title: "Aspirin five", Journal: "J1", PubYear: 2020},
+	)
+
+	tests := []struct {
+		name     string
+		page     int
+		pageSize int
+		want     []string
+	}{
+		{name: "first page", page: 1, pageSize: 2, want: []string{"1", "2"}},
+		{name: "middle page", page: 2, pageSize: 2, want: []string{"3", "4"}},
+		{name: "partial last page", page: 3, pageSize: 2, want: []string{"5"}},
+		{name: "past the end", page: 4, pageSize: 2, want: []string{}},
+		{name: "far past the end", page: 1000, pageSize: 50, want: []string{}},
+		{name: "page larger than results", page: 1, pageSize: 50, want: []string{"1", "2", "3", "4", "5"}},
+		{name: "page size one", page: 5, pageSize: 1, want: []string{"5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Search(context.Background(), &domain.SearchFilters{Query: "aspirin", Page: tt.page, PageSize: tt.pageSize})
+			require.NoError(t, err)
+
+			pmids := []string{}
+			for _, hit := range result.Items {
+				pmids = append(pmids, hit.PMID)
+			}
+			assert.Equal(t, tt.want, pmids)
+
+			// The total and the requested page are reported on every page
+			assert.Equal(t, 5, *result.Total)
+			assert.Equal(t, tt.page, result.Page)
+			assert.Equal(t, tt.pageSize, result.PageSize)
+		})
+	}
+}
+
+func testSearchSortStability(t *testing.T, newRepo repositoryFactory) {
+	// Years and relevance tie, so the PMID decides the order
+	r := newRepo(t,
+		&domain.Article{PMID: "15", Title: "Aspirin trial", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "12", Title: "Aspirin trial", Journal: "J1", PubYear: 2021},
+		&domain.Article{PMID: "14", Title: "Aspirin trial", Journal: "J1", PubYear: 2021},
+		&domain.Article{PMID: "11", Title: "Aspirin trial", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "13", Title: "Aspirin trial", Journal: "J1", PubYear: 2019},
+		&domain.Article{PMID: "16", Title: "Aspirin trial", Journal: "J1", PubYear: 2021},
+	)
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{sort: "", want: []string{"11", "12", "13", "14", "15", "16"}},
+		{sort: "year_desc", want: []string{"12", "14", "16", "11", "15", "13"}},
+		{sort: "year_asc", want: []string{"13", "11", "15", "12", "14", "16"}},
+		{sort: "relevance", want: []string{"11", "12", "13", "14", "15", "16"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("sort "+tt.sort, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin", Sort: tt.sort}))
+
+			// Paging one article at a time visits each exactly once, in the
+			// same order, and repeating a page returns the same article
+			var paged []string
+			for page := 1; page <= len(tt.want); page++ {
+				filters := &domain.SearchFilters{Query: "aspirin", Sort: tt.sort, Page: page, PageSize: 1}
+				first := searchPMIDs(t, r, filters)
+				assert.Equal(t, first, searchPMIDs(t, r, filters))
+				paged = append(paged, first...)
+			}
+			assert.Equal(t, tt.want, paged)
+		})
+	}
+}
+
+func testSearchCursor(t *testing.T, newRepo repositoryFactory) {
+	// Years and relevance tie in places, so the PMID breaks the ties
+	r := newRepo(t,
+		&domain.Article{PMID: "15", Title: "Aspirin trial", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "12", Title: "Aspirin trial of aspirin", Journal: "J1", PubYear: 2021},
+		&domain.Article{PMID: "14", Title: "Aspirin trial", Journal: "J1", PubYear: 2021},
+		&domain.Article{PMID: "11", Title: "Aspirin", Abstract: "Aspirin trial.", Journal: "J1", PubYear: 2020},
+		&domain.Article{PMID: "13", Title: "Aspirin trial", Journal: "J1", PubYear: 2019},
+		&domain.Article{PMID: "16", Title: "Aspirin trial", Journal: "J1", PubYear: 2021},
+		&domain.Article{PMID: "17", Title: "Aspirin", Abstract: "Aspirin trial.", Journal: "J1", PubYear: 2018},
+	)
+	ctx := context.Background()
+
+	for _, sort := range []string{"", "year_desc", "year_asc", "relevance"} {
+		t.Run("sort "+sort, func(t *testing.T) {
+			want := searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin", Sort: sort})
+			require.Len(t, want, 7)
+
+			// The first page is counted and positions the next one
+			result, err := r.Search(ctx, &domain.SearchFilters{Query: "aspirin", Sort: sort, Page: 1, PageSize: 3})
+			require.NoError(t, err)
+			require.NotNil(t, result.Total)
+			assert.Equal(t, 7, *result.Total)
+			require.NotNil(t, result.NextCursor)
+			assert.Equal(t, sort, result.NextCursor.Sort)
+
+			var walked []string
+			for _, hit := range result.Items {
+				walked = append(walked, hit.PMID)
+			}
+
+			// Following cursors visits every article once, in order, without
+			// counting
+			for pages := 1; result.NextCursor != nil; pages++ {
+				require.Less(t, pages, 10, "cursor does not advance")
+				result, err = r.Search(ctx, &domain.SearchFilters{Query: "aspirin", Sort: sort, PageSize: 3, After: result.NextCursor})
+				require.NoError(t, err)
+				assert.Nil(t, result.Total)
+				assert.Zero(t, result.Page)
+				for _, hit := range result.Items {
+					walked = append(walked, hit.PMID)
+				}
+			}
+			assert.Equal(t, want, walked)
+
+			// A keyset page ending on the last article is the last page
+			result, err = r.Search(ctx, &domain.SearchFilters{Query: "aspirin", Sort: sort, Page: 1, PageSize: 4})
+			require.NoError(t, err)
+			require.NotNil(t, result.NextCursor)
+			result, err = r.Search(ctx, &domain.SearchFilters{Query: "aspirin", Sort: sort, PageSize: 3, After: result.NextCursor})
+			require.NoError(t, err)
+			assert.Len(t, result.Items, 3)
+			assert.Nil(t, result.NextCursor)
+		})
+	}
+
+	// Page mode reports no next page on the last page
+	result, err := r.Search(ctx, &domain.SearchFilters{Query: "aspirin", Page: 3, PageSize: 3})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 1)
+	assert.Nil(t, result.NextCursor)
+
+	result, err = r.Search(ctx, &domain.SearchFilters{Query: "warfarin", Page: 1, PageSize: 3})
+	require.NoError(t, err)
+	assert.Nil(t, result.NextCursor)
+
+	w, ok := r.(articleWriter)
+	if !ok {
+		return
+	}
+
+	// Changes before the cursor do not shift the next page, unlike an offset
+	result, err = r.Search(ctx, &domain.SearchFilters{Sort: "year_asc", Page: 1, PageSize: 3})
+	require.NoError(t, err)
+	require.NotNil(t, result.NextCursor)
+	_, err = w.InsertArticles(ctx, []*domain.Article{{PMID: "10", Title: "Aspirin", Journal: "J1", PubYear: 2017}})
+	require.NoError(t, err)
+	_, err = w.DeleteArticles(ctx, []string{"13"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"15", "12", "14", "16"}, searchPMIDs(t, r, &domain.SearchFilters{Sort: "year_asc", After: result.NextCursor}))
+}
+
+func testGetStats(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Ibuprofen for pain", Journal: "J Clin Pharm", PubYear: 2017},
+		&domain.Article{PMID: "2", Title: "Ibuprofen for fever", Journal: "Pediatrics", PubYear: 2019},
+		&domain.Article{PMID: "3", Title: "Ibuprofen dosing", Journal: "J Clin Pharm", PubYear: 2020},
+		&domain.Article{PMID: "4", Title: "Ibuprofen safety", Journal: "J Clin Pharm", PubYear: 2021},
+		&domain.Article{PMID: "5", Title: "Aspirin for pain", Journal: "Pain Medicine", PubYear: 2021},
+	)
+	ctx := context.Background()
+
+	stats, err := r.GetStats(ctx, &domain.SearchFilters{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stats.Total)
+	assert.Equal(t, []domain.JournalCount{
+		{Journal: "J Clin Pharm", Count: 3},
+		{Journal: "Pain Medicine", Count: 1},
+		{Journal: "Pediatrics", Count: 1},
+	}, stats.TopJournals)
+	assert.Equal(t, map[int]int{2017: 1, 2019: 1, 2020: 1, 2021: 2}, stats.YearHistogram)
+
+	// "journals publishing on ibuprofen since 2018"
+	stats, err = r.GetStats(ctx, &domain.SearchFilters{
+		Expr: &domain.QueryAnd{Operands: []domain.QueryNode{
+			&domain.QueryTerm{Text: "ibuprofen", Field: domain.FieldTitleAbstract},
+			&domain.QueryYears{From: 2018, To: 9999},
+		}},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Total)
+	assert.Equal(t, []domain.JournalCount{{Journal: "J Clin Pharm", Count: 2}}, stats.TopJournals)
+	assert.Equal(t, map[int]int{2019: 1, 2020: 1, 2021: 1}, stats.YearHistogram)
+
+	// Pagination does not limit the statistics
+	stats, err = r.GetStats(ctx, &domain.SearchFilters{Query: "ibuprofen", Page: 2, PageSize: 1}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Total)
+
+	// No matches still yields empty, non-nil aggregates
+	stats, err = r.GetStats(ctx, &domain.SearchFilters{Query: "warfarin"}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Total)
+	assert.NotNil(t, stats.TopJournals)
+	assert.Empty(t, stats.TopJournals)
+	assert.Empty(t, stats.YearHistogram)
+}
+
+func testInsertArticles(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Aspirin and stroke", Journal: "J1", PubYear: 2020, MeshTerms: []string{"Aspirin", "Stroke"}},
+		&domain.Article{PMID: "2", Title: "Aspirin in pregnancy", Journal: "J1", PubYear: 2021, MeshTerms: []string{"Aspirin"}},
+	)
+	w, ok := r.(articleWriter)
+	if !ok {
+		t.Skip("repository is read-only")
+	}
+	ctx := context.Background()
+
+	// Replacing an article re-indexes its text and MeSH terms
+	replaced, err := w.InsertArticles(ctx, []*domain.Article{
+		{PMID: "1", Title: "Warfarin and stroke", Journal: "J2", PubYear: 2019, MeshTerms: []string{"Warfarin"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, replaced)
+	assert.Equal(t, []string{"2"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin"}))
+	assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "warfarin"}))
+	assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"warfarin"}}))
+	assert.Empty(t, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"Stroke"}}))
+
+	article, err := r.FindByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "J2", article.Journal)
+
+	stats, err := r.GetStats(ctx, &domain.SearchFilters{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Total)
+
+	// Articles handed to or returned by the repository do not share state
+	// with it
+	inserted := &domain.Article{PMID: "3", Title: "Heparin", Journal: "J1", PubYear: 2022, MeshTerms: []string{"Heparin"}}
+	replaced, err = w.InsertArticles(ctx, []*domain.Article{inserted})
+	require.NoError(t, err)
+	assert.Equal(t, 0, replaced)
+	inserted.MeshTerms[0] = "Changed"
+	article, err = r.FindByID(ctx, "3")
+	require.NoError(t, err)
+	article.MeshTerms[0] = "Changed too"
+	assert.Equal(t, []string{"3"}, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"Heparin"}}))
+
+	// A PMID repeated within a batch replaces its earlier record
+	replaced, err = w.InsertArticles(ctx, []*domain.Article{
+		{PMID: "4", Title: "Clopidogrel", Journal: "J1", PubYear: 2023},
+		{PMID: "4", Title: "Clopidogrel, revised", Journal: "J1", PubYear: 2023},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, replaced)
+	article, err = r.FindByID(ctx, "4")
+	require.NoError(t, err)
+	assert.Equal(t, "Clopidogrel, revised", article.Title)
+
+	// Inserting nothing is a no-op
+	replaced, err = w.InsertArticles(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, replaced)
+}
+
+func testDeleteArticles(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t,
+		&domain.Article{PMID: "1", Title: "Aspirin and stroke", Journal: "J1", PubYear: 2020, MeshTerms: []string{"Aspirin"}},
+		&domain.Article{PMID: "2", Title: "Aspirin in pregnancy", Journal: "J1", PubYear: 2021, MeshTerms: []string{"Aspirin"}},
+	)
+	w, ok := r.(articleWriter)
+	if !ok {
+		t.Skip("repository is read-only")
+	}
+	ctx := context.Background()
+
+	deleted, err := w.DeleteArticles(ctx, []string{"1", "404"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	_, err = r.FindByID(ctx, "1")
+	assert.ErrorContains(t, err, "article not found")
+	assert.Equal(t, []string{"2"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin"}))
+	assert.Equal(t, []string{"2"}, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"Aspirin"}}))
+
+	stats, err := r.GetStats(ctx, &domain.SearchFilters{}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Total)
+
+	// Deleting again is a no-op
+	deleted, err = w.DeleteArticles(ctx, []string{"1"})
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func testLoadHistory(t *testing.T, newRepo repositoryFactory) {
+	r := newRepo(t)
+	h, ok := r.(loadHistory)
+	if !ok {
+		t.Skip("repository keeps no load history")
+	}
+	ctx := context.Background()
+
+	record, err := h.FindLoad(ctx, "pubmed24n1220.xml.gz")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	loadedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, h.RecordLoad(ctx, &domain.LoadRecord{Source: "pubmed24n1220.xml.gz", Version: `"etag-1"`, Fingerprint: "sha256:aa", LoadedAt: loadedAt, Accepted: 10, Rejected: 1, Duplicates: 3, Deleted: 2}))
+	require.NoError(t, h.RecordLoad(ctx, &domain.LoadRecord{Source: "pubmed24n1221.xml.gz", Fingerprint: "sha256:bb", LoadedAt: loadedAt.Add(time.Hour), Accepted: 5}))
+
+	record, err = h.FindLoad(ctx, "pubmed24n1220.xml.gz")
+	require.NoError(t, err)
+	assert.Equal(t, &domain.LoadRecord{Source: "pubmed24n1220.xml.gz", Version: `"etag-1"`, Fingerprint: "sha256:aa", LoadedAt: loadedAt, Accepted: 10, Rejected: 1, Duplicates: 3, Deleted: 2}, record)
+
+	record, err = h.FindLoad(ctx, "pubmed24n1221.xml.gz")
+	require.NoError(t, err)
+	assert.Equal(t, 5, record.Accepted)
+
+	// Recording a source again replaces its entry
+	require.NoError(t, h.RecordLoad(ctx, &domain.LoadRecord{Source: "pubmed24n1220.xml.gz", Fingerprint: "sha256:cc", LoadedAt: loadedAt.Add(2 * time.Hour)}))
+	record, err = h.FindLoad(ctx, "pubmed24n1220.xml.gz")
+	require.NoError(t, err)
+	assert.Equal(t, &domain.LoadRecord{Source: "pubmed24n1220.xml.gz", Fingerprint: "sha256:cc", LoadedAt: loadedAt.Add(2 * time.Hour)}, record)
+}
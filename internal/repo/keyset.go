@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"pubmed-api/internal/domain"
+)
+
+// keysetCondition returns the SQL condition selecting the rows that sort
+// after the cursor, in the order of searchOrderBy. score is the relevance
+// score expression and arg adds an argument, returning its placeholder.
+//
+// The year orders are conditions the (pub_year, pmid) indexes can seek to.
+// year_asc is a row value comparison. year_desc sorts the year and the PMID
+// in opposite directions, which a row value cannot express, so it is bounded
+// by the year: the index on (pub_year DESC, pmid) seeks to the cursor's year
+// and only the earlier PMIDs of that year are skipped.
+func keysetCondition(after *domain.Cursor, sort, score string, arg func(value interface{}) string) string {
+	switch sort {
+	case "year_desc":
+		return "(pub_year <= " + arg(after.Year) + " AND (pub_year < " + arg(after.Year) + " OR pmid > " + arg(after.PMID) + "))"
+	case "year_asc":
+		return "(pub_year, pmid) > (" + arg(after.Year) + ", " + arg(after.PMID) + ")"
+	case "relevance":
+		return "(" + score + " < " + arg(after.Score) + " OR (" + score + " = " + arg(after.Score) + " AND pmid > " + arg(after.PMID) + "))"
+	}
+	return "pmid > " + arg(after.PMID)
+}
+
+// searchOrderBy returns the ORDER BY clause of a sort order, by the score
+// column for relevance. Every order ends with the PMID so that it is total:
+// a repeated page returns the same articles and a cursor identifies exactly
+// where the next page starts.
+func searchOrderBy(sort string) string {
+	switch sort {
+	case "year_desc":
+		return "pub_year DESC, pmid ASC"
+	case "year_asc":
+		return "pub_year ASC, pmid ASC"
+	case "relevance":
+		return "score DESC, pmid ASC"
+	}
+	return "pmid ASC"
+}
+
+// cursorAt returns the cursor positioned at hit
+func cursorAt(sort string, hit *domain.SearchHit) *domain.Cursor {
+	cursor := &domain.Cursor{Sort: sort, PMID: hit.PMID}
+	switch sort {
+	case "year_desc", "year_asc":
+		cursor.Year = hit.PubYear
+	case "relevance":
+		cursor.Score = hit.Score
+	}
+	return cursor
+}
+
+// pageLimit returns how many rows to fetch for a page: one more than the page
+// size in keyset mode, to tell whether another page follows
+func pageLimit(filters *domain.SearchFilters) int {
+	if filters.After != nil {
+		return filters.PageSize + 1
+	}
+	return filters.PageSize
+}
+
+// finishPage drops the extra row fetched in keyset mode and returns the hits
+// of the page with the cursor of the next one, nil on the last page. total is
+// the number of matches in page mode.
+func finishPage(filters *domain.SearchFilters, hits []*domain.SearchHit, total int) ([]*domain.SearchHit, *domain.Cursor) {
+	var more bool
+	if filters.After != nil {
+		more = len(hits) > filters.PageSize
+		hits = hits[:min(len(hits), filters.PageSize)]
+	} else {
+		more = (filters.Page-1)*filters.PageSize+len(hits) < total
+	}
+
+	if !more || len(hits) == 0 {
+		return hits, nil
+	}
+	return hits, cursorAt(filters.Sort, hits[len(hits)-1])
+}
@@ -0,0 +1,233 @@
+package repo
+
+import (
+	"math"
+	"pubmed-api/internal/domain"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// memoryField identifies a full-text field of an indexed article
+type memoryField int
+
+const (
+	memoryTitle memoryField = iota
+	memoryAbstract
+	memoryMesh
+	memoryFieldCount
+)
+
+// memoryFieldWeights are the BM25 weights of the full-text fields, the same
+// as the SQLite column weights
+var memoryFieldWeights = [memoryFieldCount]float64{
+	memoryTitle:    titleRankWeight,
+	memoryAbstract: abstractRankWeight,
+	memoryMesh:     meshRankWeight,
+}
+
+// BM25 parameters, the defaults of the FTS5 bm25() function
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// tokenize splits text into lowercase words without diacritics, the way the
+// SQLite unicode61 tokenizer with remove_diacritics 2 does
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !isTokenRune(r) && !unicode.Is(unicode.Mn, r) })
+
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		var b strings.Builder
+		for _, r := range norm.NFD.String(strings.ToLower(word)) {
+			if !unicode.Is(unicode.Mn, r) {
+				b.WriteRune(r)
+			}
+		}
+		if b.Len() > 0 {
+			tokens = append(tokens, b.String())
+		}
+	}
+	return tokens
+}
+
+// memoryDoc is an article with the tokens of its full-text fields
+type memoryDoc struct {
+	article *domain.Article
+	tokens  [memoryFieldCount][]string
+	// mesh holds the lowercase MeSH terms of the article
+	mesh map[string]struct{}
+}
+
+func newMemoryDoc(article *domain.Article) *memoryDoc {
+	doc := &memoryDoc{article: article, mesh: make(map[string]struct{}, len(article.MeshTerms))}
+	doc.tokens[memoryTitle] = tokenize(article.Title)
+	doc.tokens[memoryAbstract] = tokenize(article.Abstract)
+	doc.tokens[memoryMesh] = tokenize(strings.Join(article.MeshTerms, " "))
+	for _, term := range article.MeshTerms {
+		doc.mesh[strings.ToLower(term)] = struct{}{}
+	}
+	return doc
+}
+
+// docSet is a set of indexed articles
+type docSet map[*memoryDoc]struct{}
+
+// memoryIndex is an inverted index from tokens to the articles containing
+// them in any full-text field, with the statistics BM25 needs
+type memoryIndex struct {
+	postings map[string]docSet
+	// tokens is the total number of tokens in the full-text fields of all
+	// articles, for the average article length
+	tokens int
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{postings: make(map[string]docSet)}
+}
+
+func (ix *memoryIndex) add(doc *memoryDoc) {
+	for _, tokens := range doc.tokens {
+		ix.tokens += len(tokens)
+		for _, token := range tokens {
+			docs := ix.postings[token]
+			if docs == nil {
+				docs = make(docSet)
+				ix.postings[token] = docs
+			}
+			docs[doc] = struct{}{}
+		}
+	}
+}
+
+func (ix *memoryIndex) remove(doc *memoryDoc) {
+	for _, tokens := range doc.tokens {
+		ix.tokens -= len(tokens)
+		for _, token := range tokens {
+			if docs := ix.postings[token]; docs != nil {
+				delete(docs, doc)
+				if len(docs) == 0 {
+					delete(ix.postings, token)
+				}
+			}
+		}
+	}
+}
+
+// memoryPhrase is a full-text term compiled for matching: its tokens must
+// occur in sequence within one of the fields, the last one as a prefix when
+// prefix is set
+type memoryPhrase struct {
+	tokens []string
+	prefix bool
+	fields []memoryField
+}
+
+// memoryPhraseFields maps full-text query fields to the fields they match
+// when MeSH terms are not included
+var memoryPhraseFields = map[domain.QueryField][]memoryField{
+	domain.FieldAll:           {memoryTitle, memoryAbstract},
+	domain.FieldTitleAbstract: {memoryTitle, memoryAbstract},
+	domain.FieldTitle:         {memoryTitle},
+	domain.FieldAbstract:      {memoryAbstract},
+}
+
+func newMemoryPhrase(term *domain.QueryTerm, includeMesh bool) *memoryPhrase {
+	fields := memoryPhraseFields[term.Field]
+	if term.Field == domain.FieldAll && includeMesh {
+		fields = []memoryField{memoryTitle, memoryAbstract, memoryMesh}
+	}
+	return &memoryPhrase{tokens: tokenize(term.Text), prefix: term.Prefix, fields: fields}
+}
+
+// tokenMatches reports whether an indexed token matches the ith token of the
+// phrase
+func (p *memoryPhrase) tokenMatches(i int, token string) bool {
+	if p.prefix && i == len(p.tokens)-1 {
+		return strings.HasPrefix(token, p.tokens[i])
+	}
+	return token == p.tokens[i]
+}
+
+// candidates returns the articles containing the first token of the phrase
+// in some field; every match is among them
+func (p *memoryPhrase) candidates(ix *memoryIndex) docSet {
+	if len(p.tokens) == 0 {
+		return docSet{}
+	}
+	if !p.prefix || len(p.tokens) > 1 {
+		return ix.postings[p.tokens[0]]
+	}
+
+	docs := make(docSet)
+	for token, posting := range ix.postings {
+		if strings.HasPrefix(token, p.tokens[0]) {
+			for doc := range posting {
+				docs[doc] = struct{}{}
+			}
+		}
+	}
+	return docs
+}
+
+// count returns how many times the phrase occurs in a field of doc
+func (p *memoryPhrase) count(doc *memoryDoc, field memoryField) int {
+	tokens := doc.tokens[field]
+	n := 0
+	for start := 0; start+len(p.tokens) <= len(tokens); start++ {
+		matched := true
+		for i := range p.tokens {
+			if !p.tokenMatches(i, tokens[start+i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			n++
+		}
+	}
+	return n
+}
+
+// matches reports whether the phrase occurs in any of its fields of doc
+func (p *memoryPhrase) matches(doc *memoryDoc) bool {
+	if len(p.tokens) == 0 {
+		return false
+	}
+	for _, field := range p.fields {
+		if p.count(doc, field) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bm25 scores doc for the phrases the way the FTS5 bm25() function does: the
+// sum over phrases of the phrase's inverse document frequency times its
+// saturated frequency, where the frequency adds up the occurrences in each
+// field times the field weight and is normalized once by the length of the
+// article, all fields together, against the average article length
+func (ix *memoryIndex) bm25(doc *memoryDoc, phrases []*memoryPhrase, total int, docFreq []int) float64 {
+	docLen := 0
+	for _, tokens := range doc.tokens {
+		docLen += len(tokens)
+	}
+	avgLen := float64(ix.tokens) / float64(total)
+	lengthNorm := 1 - bm25B + bm25B*float64(docLen)/avgLen
+
+	score := 0.0
+	for i, p := range phrases {
+		idf := math.Log((float64(total) - float64(docFreq[i]) + 0.5) / (float64(docFreq[i]) + 0.5))
+		if idf <= 0 {
+			idf = 1e-6
+		}
+		freq := 0.0
+		for _, field := range p.fields {
+			freq += memoryFieldWeights[field] * float64(p.count(doc, field))
+		}
+		score += idf * freq * (bm25K1 + 1) / (freq + bm25K1*lengthNorm)
+	}
+	return score
+}
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"context"
+	"pubmed-api/internal/domain"
+)
+
+// FindLoad returns the load history entry of a source, or nil if the source
+// has never been loaded
+func (r *MemoryRepository) FindLoad(ctx context.Context, source string) (*domain.LoadRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.loads[source]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// RecordLoad adds or replaces the load history entry of record.Source
+func (r *MemoryRepository) RecordLoad(ctx context.Context, record *domain.LoadRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *record
+	stored.LoadedAt = stored.LoadedAt.UTC()
+	r.loads[record.Source] = stored
+	return nil
+}
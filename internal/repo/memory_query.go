@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"fmt"
+	"pubmed-api/internal/domain"
+	"strings"
+)
+
+// memoryQuery evaluates query ASTs against the articles of a
+// MemoryRepository
+type memoryQuery struct {
+	index *memoryIndex
+	all   map[string]*memoryDoc
+	// includeMesh extends untagged terms to MeSH terms
+	includeMesh bool
+}
+
+// eval returns the articles matched by node. Full-text terms are looked up in
+// the inverted index; other conditions scan every article.
+func (q *memoryQuery) eval(node domain.QueryNode) (docSet, error) {
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		if n.Field.IsText() {
+			phrase := newMemoryPhrase(n, q.includeMesh)
+			docs := make(docSet)
+			for doc := range phrase.candidates(q.index) {
+				if phrase.matches(doc) {
+					docs[doc] = struct{}{}
+				}
+			}
+			return docs, nil
+		}
+
+		switch n.Field {
+		case domain.FieldAuthor:
+			return q.scan(func(doc *memoryDoc) bool { return hasAuthor(doc.article, n.Text) }), nil
+		case domain.FieldJournal:
+			return q.scan(func(doc *memoryDoc) bool { return strings.EqualFold(doc.article.Journal, n.Text) }), nil
+		case domain.FieldMesh:
+			return q.scan(func(doc *memoryDoc) bool { return hasMesh(doc, n.Text) }), nil
+		}
+		return nil, fmt.Errorf("unsupported query field: %q", n.Field)
+	case *domain.QueryYears:
+		return q.scan(func(doc *memoryDoc) bool {
+			return doc.article.PubYear >= n.From && doc.article.PubYear <= n.To
+		}), nil
+	case *domain.QueryAnd:
+		var result docSet
+		for _, operand := range n.Operands {
+			docs, err := q.eval(operand)
+			if err != nil {
+				return nil, err
+			}
+			if result == nil {
+				result = docs
+				continue
+			}
+			for doc := range result {
+				if _, ok := docs[doc]; !ok {
+					delete(result, doc)
+				}
+			}
+		}
+		return result, nil
+	case *domain.QueryOr:
+		result := make(docSet)
+		for _, operand := range n.Operands {
+			docs, err := q.eval(operand)
+			if err != nil {
+				return nil, err
+			}
+			for doc := range docs {
+				result[doc] = struct{}{}
+			}
+		}
+		return result, nil
+	case *domain.QueryNot:
+		include, err := q.eval(n.Include)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err := q.eval(n.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		for doc := range exclude {
+			delete(include, doc)
+		}
+		return include, nil
+	}
+
+	return nil, fmt.Errorf("unsupported query node: %T", node)
+}
+
+// scan returns the articles for which match is true
+func (q *memoryQuery) scan(match func(doc *memoryDoc) bool) docSet {
+	docs := make(docSet)
+	for _, doc := range q.all {
+		if match(doc) {
+			docs[doc] = struct{}{}
+		}
+	}
+	return docs
+}
+
+// rankPhrases returns the full-text terms that contribute to a match, so
+// that bm25 can score rows of a query that mixes full-text and field
+// conditions. Excluded terms never occur in a matching row and are left out.
+func (q *memoryQuery) rankPhrases(node domain.QueryNode) []*memoryPhrase {
+	var phrases []*memoryPhrase
+	var collect func(domain.QueryNode)
+	collect = func(node domain.QueryNode) {
+		switch n := node.(type) {
+		case *domain.QueryTerm:
+			if n.Field.IsText() {
+				phrases = append(phrases, newMemoryPhrase(n, q.includeMesh))
+			}
+		case *domain.QueryAnd:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryOr:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryNot:
+			collect(n.Include)
+		}
+	}
+	collect(node)
+	return phrases
+}
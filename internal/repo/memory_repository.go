@@ -0,0 +1,450 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"pubmed-api/internal/domain"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository implements ArticleRepository in memory, without CGO.
+// Articles are kept in a map by PMID with an inverted token index for
+// full-text search, and are lost when the process exits. It suits small
+// read-only deployments and tests.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	articles map[string]*memoryDoc
+	index    *memoryIndex
+	loads    map[string]domain.LoadRecord
+	logger   *slog.Logger
+}
+
+// Assert MemoryRepository implements ArticleRepository
+var _ ArticleRepository = (*MemoryRepository)(nil)
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository(logger *slog.Logger) *MemoryRepository {
+	return &MemoryRepository{
+		articles: make(map[string]*memoryDoc),
+		index:    newMemoryIndex(),
+		loads:    make(map[string]domain.LoadRecord),
+		logger:   logger,
+	}
+}
+
+// LoadData is a no-op; data is loaded by the platform layer through
+// InsertArticles
+func (r *MemoryRepository) LoadData(ctx context.Context, dataPath string) error {
+	return nil
+}
+
+// InsertArticles stores articles, replacing existing articles with the same
+// PMID, and returns how many it replaced
+func (r *MemoryRepository) InsertArticles(ctx context.Context, articles []*domain.Article) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := 0
+	for _, article := range articles {
+		if old, ok := r.articles[article.PMID]; ok {
+			r.index.remove(old)
+			replaced++
+		}
+		doc := newMemoryDoc(copyArticle(article))
+		r.articles[article.PMID] = doc
+		r.index.add(doc)
+	}
+
+	r.logger.Debug("loaded articles", "count", len(articles), "replaced", replaced)
+	return replaced, nil
+}
+
+// DeleteArticles deletes the articles with the given PMIDs and returns how
+// many existed. Unknown PMIDs are ignored.
+func (r *MemoryRepository) DeleteArticles(ctx context.Context, pmids []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for _, pmid := range pmids {
+		if doc, ok := r.articles[pmid]; ok {
+			r.index.remove(doc)
+			delete(r.articles, pmid)
+			deleted++
+		}
+	}
+
+	r.logger.Debug("deleted articles", "requested", len(pmids), "deleted", deleted)
+	return deleted, nil
+}
+
+// FindByID retrieves an article by its PubMed ID
+func (r *MemoryRepository) FindByID(ctx context.Context, pmid string) (*domain.Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	doc, ok := r.articles[pmid]
+	if !ok {
+		return nil, fmt.Errorf("article not found: %s", pmid)
+	}
+	return copyArticle(doc.article), nil
+}
+
+// FindByIDs retrieves the articles with the given PubMed IDs
+func (r *MemoryRepository) FindByIDs(ctx context.Context, pmids []string) ([]*domain.Article, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var articles []*domain.Article
+	seen := make(map[string]bool, len(pmids))
+	for _, pmid := range pmids {
+		if doc, ok := r.articles[pmid]; ok && !seen[pmid] {
+			seen[pmid] = true
+			articles = append(articles, copyArticle(doc.article))
+		}
+	}
+	return articles, nil
+}
+
+// copyArticle returns a copy of an article that does not share its slices
+func copyArticle(article *domain.Article) *domain.Article {
+	copied := *article
+	copied.Authors = slices.Clone(article.Authors)
+	copied.MeshTerms = slices.Clone(article.MeshTerms)
+	return &copied
+}
+
+// Search performs a search with filters and pagination
+func (r *MemoryRepository) Search(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
+	startTime := time.Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched, ranked, err := r.match(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[*memoryDoc]float64, len(matched))
+	if len(ranked) > 0 {
+		docFreq := make([]int, len(ranked))
+		for i, p := range ranked {
+			for doc := range p.candidates(r.index) {
+				if p.matches(doc) {
+					docFreq[i]++
+				}
+			}
+		}
+		for _, doc := range matched {
+			scores[doc] = r.index.bm25(doc, ranked, len(r.articles), docFreq)
+		}
+	}
+
+	// Every sort ends with the PMID so that pages are stable
+	less := func(a, b memorySortKey) bool {
+		switch filters.Sort {
+		case "year_desc":
+			if a.year != b.year {
+				return a.year > b.year
+			}
+		case "year_asc":
+			if a.year != b.year {
+				return a.year < b.year
+			}
+		case "relevance":
+			if a.score != b.score {
+				return a.score > b.score
+			}
+		}
+		return a.pmid < b.pmid
+	}
+	key := func(doc *memoryDoc) memorySortKey {
+		return memorySortKey{year: doc.article.PubYear, score: scores[doc], pmid: doc.article.PMID}
+	}
+	sort.Slice(matched, func(i, j int) bool { return less(key(matched[i]), key(matched[j])) })
+
+	// Keyset pages are not counted; they start after the cursor instead of
+	// at an offset
+	var offset int
+	if filters.After != nil {
+		after := memorySortKey{year: filters.After.Year, score: filters.After.Score, pmid: filters.After.PMID}
+		offset = sort.Search(len(matched), func(i int) bool { return less(after, key(matched[i])) })
+	} else {
+		offset = min(max((filters.Page-1)*filters.PageSize, 0), len(matched))
+	}
+	end := min(offset+pageLimit(filters), len(matched))
+
+	var hits []*domain.SearchHit
+	for _, doc := range matched[offset:end] {
+		hits = append(hits, &domain.SearchHit{Article: copyArticle(doc.article), Score: scores[doc]})
+	}
+	hits, next := finishPage(filters, hits, len(matched))
+
+	var facets map[string][]domain.FacetCount
+	if len(filters.Facets) > 0 {
+		facets = make(map[string][]domain.FacetCount, len(filters.Facets))
+		for _, facet := range filters.Facets {
+			counts, err := countMemoryFacet(matched, facet)
+			if err != nil {
+				return nil, err
+			}
+			facets[facet] = counts
+		}
+	}
+
+	tookMs := time.Since(startTime).Milliseconds()
+
+	result := &domain.SearchResult{
+		Items:      hits,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+		TookMs:     tookMs,
+		NextCursor: next,
+		Facets:     facets,
+	}
+	if filters.After == nil {
+		total := len(matched)
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// memorySortKey holds the fields results are sorted by
+type memorySortKey struct {
+	year  int
+	score float64
+	pmid  string
+}
+
+// countMemoryFacet counts the most frequent values of a facet over the docs.
+// Multi-valued fields are counted once per article, MeSH terms ignoring
+// case.
+func countMemoryFacet(docs []*memoryDoc, facet string) ([]domain.FacetCount, error) {
+	var values func(a *domain.Article) []string
+	switch facet {
+	case domain.FacetJournal:
+		values = func(a *domain.Article) []string { return []string{a.Journal} }
+	case domain.FacetYear:
+		values = func(a *domain.Article) []string { return []string{strconv.Itoa(a.PubYear)} }
+	case domain.FacetAuthor:
+		values = func(a *domain.Article) []string { return a.Authors }
+	case domain.FacetMesh:
+		values = func(a *domain.Article) []string { return a.MeshTerms }
+	default:
+		return nil, fmt.Errorf("unknown facet: %s", facet)
+	}
+
+	counts := make(map[string]*domain.FacetCount)
+	for _, doc := range docs {
+		seen := make(map[string]bool)
+		for _, value := range values(doc.article) {
+			key := value
+			if facet == domain.FacetMesh {
+				key = strings.ToLower(value)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			fc, ok := counts[key]
+			if !ok {
+				fc = &domain.FacetCount{Value: value}
+				counts[key] = fc
+			}
+			fc.Value = min(fc.Value, value)
+			fc.Count++
+		}
+	}
+
+	return topCounts(counts, facetLimit), nil
+}
+
+// topCounts returns at most limit counts, most frequent first and then by
+// value
+func topCounts(counts map[string]*domain.FacetCount, limit int) []domain.FacetCount {
+	sorted := make([]domain.FacetCount, 0, len(counts))
+	for _, fc := range counts {
+		sorted = append(sorted, *fc)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted[:min(limit, len(sorted))]
+}
+
+// match returns the articles matching filters, in no particular order, and
+// the phrases that rank them
+func (r *MemoryRepository) match(filters *domain.SearchFilters) ([]*memoryDoc, []*memoryPhrase, error) {
+	q := &memoryQuery{index: r.index, all: r.articles, includeMesh: filters.IncludeMesh}
+
+	var docs docSet
+	expr := filters.Expr
+	if expr == nil && filters.Query != "" {
+		expr = plainQuery(filters.Query)
+		if expr == nil {
+			// Nothing in the query can match an indexed token
+			return nil, nil, nil
+		}
+	}
+
+	if expr != nil {
+		var err error
+		if docs, err = q.eval(expr); err != nil {
+			return nil, nil, fmt.Errorf("failed to compile query: %w", err)
+		}
+	}
+
+	var matched []*memoryDoc
+	consider := func(doc *memoryDoc) {
+		if memoryFiltersMatch(doc, filters) {
+			matched = append(matched, doc)
+		}
+	}
+	if docs != nil {
+		for doc := range docs {
+			consider(doc)
+		}
+	} else {
+		for _, doc := range r.articles {
+			consider(doc)
+		}
+	}
+
+	var ranked []*memoryPhrase
+	if expr != nil {
+		ranked = q.rankPhrases(expr)
+	}
+	return matched, ranked, nil
+}
+
+// memoryFiltersMatch reports whether doc passes the year, journal, author and
+// MeSH filters
+func memoryFiltersMatch(doc *memoryDoc, filters *domain.SearchFilters) bool {
+	a := doc.article
+
+	if filters.YearFrom != nil && a.PubYear < *filters.YearFrom {
+		return false
+	}
+
+	if filters.YearTo != nil && a.PubYear > *filters.YearTo {
+		return false
+	}
+
+	if len(filters.Journals) > 0 && !slices.Contains(filters.Journals, a.Journal) {
+		return false
+	}
+
+	if len(filters.Authors) > 0 {
+		anyFound := false
+		for _, author := range filters.Authors {
+			found := hasAuthor(a, author)
+			if !found && filters.AuthorOperator != domain.OperatorOr {
+				return false
+			}
+			anyFound = anyFound || found
+		}
+		if !anyFound {
+			return false
+		}
+	}
+
+	if len(filters.Mesh) > 0 {
+		// An exploded term matches itself or any of its narrower terms
+		anyFound := false
+		for _, term := range filters.Mesh {
+			found := hasMesh(doc, term)
+			for _, narrower := range filters.MeshExpansions[term] {
+				found = found || hasMesh(doc, narrower)
+			}
+			if !found && filters.MeshOperator != domain.OperatorOr {
+				return false
+			}
+			anyFound = anyFound || found
+		}
+		if !anyFound {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasAuthor reports whether an author name of the article contains s,
+// ignoring case
+func hasAuthor(a *domain.Article, s string) bool {
+	s = strings.ToLower(s)
+	for _, author := range a.Authors {
+		if strings.Contains(strings.ToLower(author), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasMesh reports whether the article is indexed with a MeSH term, ignoring
+// case
+func hasMesh(doc *memoryDoc, term string) bool {
+	_, ok := doc.mesh[strings.ToLower(term)]
+	return ok
+}
+
+// GetStats returns aggregate statistics over the articles matching filters
+func (r *MemoryRepository) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	matched, _, err := r.match(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	journals := make(map[string]*domain.FacetCount)
+	yearHistogram := make(map[int]int)
+	for _, doc := range matched {
+		fc, ok := journals[doc.article.Journal]
+		if !ok {
+			fc = &domain.FacetCount{Value: doc.article.Journal}
+			journals[doc.article.Journal] = fc
+		}
+		fc.Count++
+		yearHistogram[doc.article.PubYear]++
+	}
+
+	journalCounts := []domain.JournalCount{}
+	for _, fc := range topCounts(journals, topJournals) {
+		journalCounts = append(journalCounts, domain.JournalCount{Journal: fc.Value, Count: fc.Count})
+	}
+
+	return &domain.Stats{
+		Total:         len(matched),
+		TopJournals:   journalCounts,
+		YearHistogram: yearHistogram,
+	}, nil
+}
+
+// Migrate is a no-op since an in-memory repository has no schema
+func (r *MemoryRepository) Migrate(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// MigrationStatus returns no migrations since an in-memory repository has no
+// schema
+func (r *MemoryRepository) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return []MigrationStatus{}, nil
+}
+
+// Close releases nothing; the articles stay readable
+func (r *MemoryRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,27 @@
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"pubmed-api/internal/domain"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMemoryRepository creates a repository seeded with articles
+func newTestMemoryRepository(t *testing.T, articles ...*domain.Article) *MemoryRepository {
+	t.Helper()
+
+	r := NewMemoryRepository(slog.Default())
+	_, err := r.InsertArticles(context.Background(), articles)
+	require.NoError(t, err)
+	return r
+}
+
+func TestMemoryRepository_Conformance(t *testing.T) {
+	testArticleRepository(t, func(t *testing.T, articles ...*domain.Article) ArticleRepository {
+		return newTestMemoryRepository(t, articles...)
+	})
+}
@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// migration is a versioned change to a repository schema. Migrations are
+// applied in version order, each in its own transaction, and recorded in the
+// schema_migrations table. Released migrations must never be edited; change
+// the schema by appending a new one.
+type migration struct {
+	version int
+	name    string
+	sql     string
+	// upgrade, if set, runs in the migration's transaction before sql, to
+	// bring a database created before migrations existed to the state sql
+	// expects
+	upgrade func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus describes a schema migration and whether it has been
+// applied
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// AppliedAt is zero for a pending migration
+	AppliedAt time.Time
+}
+
+// schemaMigrator applies migrations to a SQL database and records them in
+// its schema_migrations table
+type schemaMigrator struct {
+	db     *sql.DB
+	logger *slog.Logger
+	// placeholder returns the bind parameter for the nth (1-based) argument
+	// in the dialect of db
+	placeholder func(n int) string
+}
+
+func (m *schemaMigrator) migrate(ctx context.Context, migrations []migration) (int, error) {
+	statuses, err := m.migrationStatus(ctx, migrations)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for i, status := range statuses {
+		if !status.AppliedAt.IsZero() {
+			continue
+		}
+		if err := m.applyMigration(ctx, migrations[i]); err != nil {
+			return applied, err
+		}
+		m.logger.Info("applied schema migration", "version", status.Version, "name", status.Name)
+		applied++
+	}
+	return applied, nil
+}
+
+func (m *schemaMigrator) applyMigration(ctx context.Context, mig migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if mig.upgrade != nil {
+		if err := mig.upgrade(ctx, tx); err != nil {
+			return fmt.Errorf("failed to apply migration %d (%s): %w", mig.version, mig.name, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, mig.sql); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return fmt.Errorf("failed to apply migration %d (build with -tags sqlite_fts5): %w", mig.version, err)
+		}
+		return fmt.Errorf("failed to apply migration %d (%s): %w", mig.version, mig.name, err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s)
+	`, m.placeholder(1), m.placeholder(2), m.placeholder(3)), mig.version, mig.name, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", mig.version, err)
+	}
+
+	return tx.Commit()
+}
+
+func (m *schemaMigrator) migrationStatus(ctx context.Context, migrations []migration) ([]MigrationStatus, error) {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at BIGINT NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	latest := 0
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		appliedAt[version] = time.Unix(0, at).UTC()
+		latest = max(latest, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+
+	if known := migrations[len(migrations)-1].version; latest > known {
+		return nil, fmt.Errorf("database schema version %d is newer than this build supports (%d)", latest, known)
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, mig := range migrations {
+		statuses[i] = MigrationStatus{Version: mig.version, Name: mig.name, AppliedAt: appliedAt[mig.version]}
+	}
+	return statuses, nil
+}
+
+// PendingMigrations returns the number of schema migrations not yet applied
+func PendingMigrations(statuses []MigrationStatus) int {
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt.IsZero() {
+			pending++
+		}
+	}
+	return pending
+}
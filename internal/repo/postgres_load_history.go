@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"pubmed-api/internal/domain"
+	"time"
+)
+
+// FindLoad returns the load history entry of a source, or nil if the source
+// has never been loaded
+func (r *PostgresRepository) FindLoad(ctx context.Context, source string) (*domain.LoadRecord, error) {
+	var record domain.LoadRecord
+	var loadedAt int64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT source, version, fingerprint, loaded_at, accepted, rejected, duplicates, deleted
+		FROM load_history WHERE source = $1
+	`, source).Scan(&record.Source, &record.Version, &record.Fingerprint, &loadedAt, &record.Accepted, &record.Rejected, &record.Duplicates, &record.Deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query load history: %w", err)
+	}
+
+	record.LoadedAt = time.Unix(0, loadedAt).UTC()
+	return &record, nil
+}
+
+// RecordLoad adds or replaces the load history entry of record.Source
+func (r *PostgresRepository) RecordLoad(ctx context.Context, record *domain.LoadRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO load_history (source, version, fingerprint, loaded_at, accepted, rejected, duplicates, deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (source) DO UPDATE SET
+			version = excluded.version,
+			fingerprint = excluded.fingerprint,
+			loaded_at = excluded.loaded_at,
+			accepted = excluded.accepted,
+			rejected = excluded.rejected,
+			duplicates = excluded.duplicates,
+			deleted = excluded.deleted
+	`, record.Source, record.Version, record.Fingerprint, record.LoadedAt.UnixNano(), record.Accepted, record.Rejected, record.Duplicates, record.Deleted)
+	if err != nil {
+		return fmt.Errorf("failed to record load: %w", err)
+	}
+	return nil
+}
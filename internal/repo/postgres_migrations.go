@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"strconv"
+)
+
+// postgresMigrations is the schema history of the PostgreSQL repository.
+//
+// search_vector holds the full-text index of an article: title lexemes carry
+// weight A, MeSH terms weight B and abstract lexemes weight C, so that a
+// tsquery can be restricted to fields by weight and ts_rank can weigh them
+// like the SQLite bm25 ranking. A trigger computes it because
+// array_to_string is not immutable and cannot be used in a generated column.
+// The 'simple' configuration lowercases words without stemming them, like
+// the SQLite unicode61 tokenizer, and unaccent removes their diacritics,
+// like its remove_diacritics option; queries are unaccented the same way.
+// Creating the unaccent extension takes the CREATE privilege on the
+// database, so the first migration must run as a role that has it, or after
+// an administrator has created the extension.
+// PMIDs and journals use the "C" collation to sort byte-wise, as SQLite does.
+//
+// Authors and MeSH terms are text arrays. article_mesh normalizes mesh_terms
+// into one row per term, unique ignoring case, for exact MeSH filtering; a
+// trigger keeps it in sync and the foreign key removes the rows of deleted
+// articles.
+var postgresMigrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		sql: `
+	CREATE EXTENSION IF NOT EXISTS unaccent;
+
+	CREATE TABLE articles (
+		id BIGSERIAL PRIMARY KEY,
+		pmid TEXT COLLATE "C" NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		abstract TEXT NOT NULL DEFAULT '',
+		authors TEXT[] NOT NULL DEFAULT '{}',
+		journal TEXT COLLATE "C" NOT NULL,
+		pub_year INTEGER,
+		mesh_terms TEXT[] NOT NULL DEFAULT '{}',
+		doi TEXT NOT NULL DEFAULT '',
+		search_vector TSVECTOR NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX idx_articles_pub_year ON articles(pub_year);
+	CREATE INDEX idx_articles_journal ON articles(journal);
+	CREATE INDEX idx_articles_search_vector ON articles USING GIN (search_vector);
+
+	CREATE FUNCTION articles_search_vector() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector :=
+			setweight(to_tsvector('simple', unaccent(NEW.title)), 'A') ||
+			setweight(to_tsvector('simple', unaccent(array_to_string(NEW.mesh_terms, ' '))), 'B') ||
+			setweight(to_tsvector('simple', unaccent(NEW.abstract)), 'C');
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER articles_search_vector BEFORE INSERT OR UPDATE OF title, abstract, mesh_terms ON articles
+		FOR EACH ROW EXECUTE FUNCTION articles_search_vector();
+
+	CREATE TABLE article_mesh (
+		article_id BIGINT NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+		term TEXT NOT NULL
+	);
+
+	CREATE UNIQUE INDEX idx_article_mesh_article_term ON article_mesh(article_id, lower(term));
+	CREATE INDEX idx_article_mesh_term ON article_mesh(lower(term), article_id);
+
+	CREATE FUNCTION article_mesh_sync() RETURNS trigger AS $$
+	BEGIN
+		DELETE FROM article_mesh WHERE article_id = NEW.id;
+		INSERT INTO article_mesh(article_id, term)
+		SELECT DISTINCT ON (lower(term)) NEW.id, term
+		FROM unnest(NEW.mesh_terms) WITH ORDINALITY AS m(term, n)
+		ORDER BY lower(term), n;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	CREATE TRIGGER article_mesh_sync AFTER INSERT OR UPDATE OF mesh_terms ON articles
+		FOR EACH ROW EXECUTE FUNCTION article_mesh_sync();
+
+	CREATE TABLE load_history (
+		source TEXT PRIMARY KEY,
+		version TEXT NOT NULL DEFAULT '',
+		fingerprint TEXT NOT NULL,
+		loaded_at BIGINT NOT NULL,
+		accepted INTEGER NOT NULL,
+		rejected INTEGER NOT NULL,
+		duplicates INTEGER NOT NULL,
+		deleted INTEGER NOT NULL
+	);
+
+	CREATE INDEX idx_load_history_loaded_at ON load_history(loaded_at);
+	`,
+	},
+	{
+		// Keyset pages sorted by year seek to the cursor instead of scanning
+		// and sorting the articles; the indexes also serve year filters
+		version: 2,
+		name:    "index articles by year and PMID",
+		sql: `
+	CREATE INDEX idx_articles_year_pmid ON articles(pub_year, pmid);
+	CREATE INDEX idx_articles_year_desc_pmid ON articles(pub_year DESC, pmid);
+	DROP INDEX idx_articles_pub_year;
+	`,
+	},
+}
+
+// Migrate applies the pending schema migrations and returns how many were
+// applied
+func (r *PostgresRepository) Migrate(ctx context.Context) (int, error) {
+	return r.migrator().migrate(ctx, postgresMigrations)
+}
+
+// MigrationStatus lists every schema migration known to this build, in
+// version order, with the time each was applied
+func (r *PostgresRepository) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return r.migrator().migrationStatus(ctx, postgresMigrations)
+}
+
+func (r *PostgresRepository) migrator() *schemaMigrator {
+	return &schemaMigrator{db: r.db, logger: r.logger, placeholder: pgPlaceholder}
+}
+
+// pgPlaceholder returns the PostgreSQL bind parameter of the nth argument
+func pgPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
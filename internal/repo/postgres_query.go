@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"fmt"
+	"pubmed-api/internal/domain"
+	"strings"
+)
+
+// pgWeights maps full-text query fields to the search_vector weights they
+// match when MeSH terms are not included
+var pgWeights = map[domain.QueryField]string{
+	domain.FieldAll:           "AC",
+	domain.FieldTitleAbstract: "AC",
+	domain.FieldTitle:         "A",
+	domain.FieldAbstract:      "C",
+}
+
+// pgRankWeights are the ts_rank weights of the {D, C, B, A} labels of
+// search_vector: abstract, MeSH terms and title, scaled from the bm25 column
+// weights of the SQLite repository so that both rank in the same order
+var pgRankWeights = fmt.Sprintf("'{0, %g, %g, %g}'",
+	abstractRankWeight/titleRankWeight, meshRankWeight/titleRankWeight, 1.0)
+
+// pgArgs collects the arguments of a PostgreSQL statement
+type pgArgs []interface{}
+
+// add appends an argument and returns its placeholder
+func (a *pgArgs) add(value interface{}) string {
+	*a = append(*a, value)
+	return pgPlaceholder(len(*a))
+}
+
+// postgresQuery compiles query ASTs into tsquery expressions and SQL
+// conditions
+type postgresQuery struct {
+	// includeMesh extends untagged terms to MeSH terms
+	includeMesh bool
+}
+
+// tsTerm renders a full-text term as a to_tsquery operand. The text is always
+// quoted so user input can never be interpreted as tsquery syntax; the
+// 'simple' parser splits it into the same words as the indexed text and
+// matches them as a phrase.
+func (q postgresQuery) tsTerm(term *domain.QueryTerm) string {
+	weights := pgWeights[term.Field]
+	if term.Field == domain.FieldAll && q.includeMesh {
+		weights = "ABC"
+	}
+	if term.Prefix {
+		weights = "*" + weights
+	}
+
+	text := strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(term.Text)
+	return "'" + text + "':" + weights
+}
+
+// tsExpr renders node as a single to_tsquery expression. It reports false
+// when the subtree contains anything other than full-text terms.
+func (q postgresQuery) tsExpr(node domain.QueryNode) (string, bool) {
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		if !n.Field.IsText() {
+			return "", false
+		}
+		return q.tsTerm(n), true
+	case *domain.QueryAnd:
+		return q.tsJoin(n.Operands, " & ")
+	case *domain.QueryOr:
+		return q.tsJoin(n.Operands, " | ")
+	case *domain.QueryNot:
+		include, ok := q.tsExpr(n.Include)
+		if !ok {
+			return "", false
+		}
+		exclude, ok := q.tsExpr(n.Exclude)
+		if !ok {
+			return "", false
+		}
+		return "(" + include + " & !(" + exclude + "))", true
+	default:
+		return "", false
+	}
+}
+
+func (q postgresQuery) tsJoin(operands []domain.QueryNode, op string) (string, bool) {
+	parts := make([]string, 0, len(operands))
+	for _, operand := range operands {
+		part, ok := q.tsExpr(operand)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, op) + ")", true
+}
+
+// tsRankExpr ORs together the full-text terms that contribute to a match, so
+// ts_rank can score rows of a query that mixes full-text and field
+// conditions. Excluded terms never occur in a matching row and are left out.
+func (q postgresQuery) tsRankExpr(node domain.QueryNode) (string, bool) {
+	var terms []string
+	var collect func(domain.QueryNode)
+	collect = func(node domain.QueryNode) {
+		switch n := node.(type) {
+		case *domain.QueryTerm:
+			if n.Field.IsText() {
+				terms = append(terms, q.tsTerm(n))
+			}
+		case *domain.QueryAnd:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryOr:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryNot:
+			collect(n.Include)
+		}
+	}
+	collect(node)
+
+	if len(terms) == 0 {
+		return "", false
+	}
+	return strings.Join(terms, " | "), true
+}
+
+// sqlWhere compiles node into an SQL condition over the articles table,
+// adding its arguments to args. Full-text subtrees become a single match
+// against search_vector.
+func (q postgresQuery) sqlWhere(node domain.QueryNode, args *pgArgs) (string, error) {
+	if expr, ok := q.tsExpr(node); ok {
+		return "articles.search_vector @@ to_tsquery('simple', unaccent(" + args.add(expr) + "))", nil
+	}
+
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		switch n.Field {
+		case domain.FieldAuthor:
+			return "EXISTS (SELECT 1 FROM unnest(articles.authors) AS author WHERE author ILIKE " + args.add("%"+n.Text+"%") + ")", nil
+		case domain.FieldJournal:
+			return "lower(articles.journal) = lower(" + args.add(n.Text) + ")", nil
+		case domain.FieldMesh:
+			return "articles.id IN (SELECT article_id FROM article_mesh WHERE lower(term) = lower(" + args.add(n.Text) + "))", nil
+		}
+		return "", fmt.Errorf("unsupported query field: %q", n.Field)
+	case *domain.QueryYears:
+		return "articles.pub_year BETWEEN " + args.add(n.From) + " AND " + args.add(n.To), nil
+	case *domain.QueryAnd:
+		return q.sqlJoin(n.Operands, " AND ", args)
+	case *domain.QueryOr:
+		return q.sqlJoin(n.Operands, " OR ", args)
+	case *domain.QueryNot:
+		include, err := q.sqlWhere(n.Include, args)
+		if err != nil {
+			return "", err
+		}
+		exclude, err := q.sqlWhere(n.Exclude, args)
+		if err != nil {
+			return "", err
+		}
+		return "(" + include + " AND NOT " + exclude + ")", nil
+	}
+
+	return "", fmt.Errorf("unsupported query node: %T", node)
+}
+
+func (q postgresQuery) sqlJoin(operands []domain.QueryNode, op string, args *pgArgs) (string, error) {
+	parts := make([]string, 0, len(operands))
+	for _, operand := range operands {
+		part, err := q.sqlWhere(operand, args)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, op) + ")", nil
+}
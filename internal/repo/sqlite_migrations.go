@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqliteMigrations is the schema history of the SQLite repository.
+//
+// Migration 1 is the schema that initSchema created before migrations were
+// introduced. Its statements are idempotent so that it can be applied to such
+// a database as well as to an empty one. Databases from the first initSchema,
+// whose articles were keyed by pmid, are rebuilt by upgradeLegacyArticles
+// first; the search indexes are then rebuilt from the articles, which also
+// fills them for articles stored before the triggers existed.
+//
+// articles_fts is an external-content FTS5 table over articles, keyed by the
+// articles.id rowid. The triggers keep it in sync with every insert, update and
+// delete, so writers only ever touch the articles table. mesh_terms is indexed
+// straight from its JSON encoding; the tokenizer drops the JSON punctuation.
+//
+// article_mesh normalizes the mesh_terms JSON array into one row per term for
+// exact, case-insensitive MeSH filtering. It is maintained by the same
+// triggers.
+//
+// load_history records the data sources that have been loaded and which
+// revision of each, so that a source such as a PubMed update file is applied
+// only once and unchanged sources are not reloaded on restart.
+var sqliteMigrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		upgrade: upgradeLegacyArticles,
+		sql: sqliteArticlesTable + `
+	CREATE INDEX IF NOT EXISTS idx_pub_year ON articles(pub_year);
+	CREATE INDEX IF NOT EXISTS idx_journal ON articles(journal);
+	DROP INDEX IF EXISTS idx_search_text;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS articles_fts USING fts5(
+		title,
+		abstract,
+		mesh_terms,
+		content = 'articles',
+		content_rowid = 'id',
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+
+	CREATE TABLE IF NOT EXISTS article_mesh (
+		article_id INTEGER NOT NULL REFERENCES articles(id),
+		term TEXT NOT NULL COLLATE NOCASE,
+		PRIMARY KEY (article_id, term)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_article_mesh_term ON article_mesh(term, article_id);
+
+	CREATE TRIGGER IF NOT EXISTS article_mesh_insert AFTER INSERT ON articles BEGIN
+		INSERT OR IGNORE INTO article_mesh(article_id, term)
+		SELECT new.id, value FROM json_each(new.mesh_terms) WHERE type = 'text';
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS article_mesh_delete AFTER DELETE ON articles BEGIN
+		DELETE FROM article_mesh WHERE article_id = old.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS article_mesh_update AFTER UPDATE OF mesh_terms ON articles BEGIN
+		DELETE FROM article_mesh WHERE article_id = old.id;
+		INSERT OR IGNORE INTO article_mesh(article_id, term)
+		SELECT new.id, value FROM json_each(new.mesh_terms) WHERE type = 'text';
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS articles_fts_insert AFTER INSERT ON articles BEGIN
+		INSERT INTO articles_fts(rowid, title, abstract, mesh_terms)
+		VALUES (new.id, new.title, new.abstract, new.mesh_terms);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS articles_fts_delete AFTER DELETE ON articles BEGIN
+		INSERT INTO articles_fts(articles_fts, rowid, title, abstract, mesh_terms)
+		VALUES ('delete', old.id, old.title, old.abstract, old.mesh_terms);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS articles_fts_update AFTER UPDATE ON articles BEGIN
+		INSERT INTO articles_fts(articles_fts, rowid, title, abstract, mesh_terms)
+		VALUES ('delete', old.id, old.title, old.abstract, old.mesh_terms);
+		INSERT INTO articles_fts(rowid, title, abstract, mesh_terms)
+		VALUES (new.id, new.title, new.abstract, new.mesh_terms);
+	END;
+
+	CREATE TABLE IF NOT EXISTS load_history (
+		source TEXT PRIMARY KEY,
+		version TEXT NOT NULL DEFAULT '',
+		fingerprint TEXT NOT NULL,
+		loaded_at INTEGER NOT NULL,
+		accepted INTEGER NOT NULL,
+		rejected INTEGER NOT NULL,
+		duplicates INTEGER NOT NULL,
+		deleted INTEGER NOT NULL
+	);
+
+	INSERT INTO articles_fts(articles_fts) VALUES ('rebuild');
+
+	INSERT OR IGNORE INTO article_mesh(article_id, term)
+	SELECT articles.id, mesh.value
+	FROM articles, json_each(articles.mesh_terms) AS mesh
+	WHERE json_valid(articles.mesh_terms) AND mesh.type = 'text';
+	`,
+	},
+	{
+		version: 2,
+		name:    "index load history by load time",
+		sql: `
+	CREATE INDEX idx_load_history_loaded_at ON load_history(loaded_at);
+	`,
+	},
+	{
+		// Keyset pages sorted by year seek to the cursor instead of scanning
+		// and sorting the articles; the indexes also serve year filters
+		version: 3,
+		name:    "index articles by year and PMID",
+		sql: `
+	CREATE INDEX idx_articles_year_pmid ON articles(pub_year, pmid);
+	CREATE INDEX idx_articles_year_desc_pmid ON articles(pub_year DESC, pmid);
+	DROP INDEX idx_pub_year;
+	`,
+	},
+	{
+		// Articles without MeSH terms were stored with a JSON null, which
+		// articles_fts indexed as the word "null"; the update trigger
+		// reindexes them
+		version: 4,
+		name:    "store missing MeSH terms as an empty array",
+		sql: `
+	UPDATE articles SET mesh_terms = '[]' WHERE mesh_terms = 'null';
+	`,
+	},
+}
+
+// sqliteArticlesTable creates the articles table of migration 1
+const sqliteArticlesTable = `
+	CREATE TABLE IF NOT EXISTS articles (
+		id INTEGER PRIMARY KEY,
+		pmid TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		abstract TEXT,
+		authors TEXT NOT NULL,
+		journal TEXT NOT NULL,
+		pub_year INTEGER,
+		mesh_terms TEXT,
+		doi TEXT
+	);
+`
+
+// upgradeLegacyArticles rebuilds an articles table created by the first
+// initSchema, keyed by pmid and with a search_text column, into the layout
+// of migration 1, which keys articles by an integer id for the search
+// indexes. A table in neither layout is an error, rather than a migration
+// recorded over a schema it does not match.
+func upgradeLegacyArticles(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info('articles')")
+	if err != nil {
+		return fmt.Errorf("failed to inspect articles table: %w", err)
+	}
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to inspect articles table: %w", err)
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect articles table: %w", err)
+	}
+
+	switch {
+	case len(columns) == 0, columns["id"]:
+		// A new database, or already in the current layout
+		return nil
+	case !columns["pmid"] || !columns["search_text"]:
+		return fmt.Errorf("articles table has an unrecognized layout")
+	}
+
+	// The indexes move with the renamed table, so they are dropped for
+	// migration 1 to create them on the new one
+	_, err = tx.ExecContext(ctx, `
+		ALTER TABLE articles RENAME TO articles_legacy;
+		DROP INDEX IF EXISTS idx_search_text;
+		DROP INDEX IF EXISTS idx_pub_year;
+		DROP INDEX IF EXISTS idx_journal;
+	`+sqliteArticlesTable+`
+		INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi)
+		SELECT pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi
+		FROM articles_legacy
+		ORDER BY pmid;
+		DROP TABLE articles_legacy;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild legacy articles table: %w", err)
+	}
+	return nil
+}
+
+// Migrate applies the pending schema migrations and returns how many were
+// applied
+func (r *SQLiteRepository) Migrate(ctx context.Context) (int, error) {
+	return r.migrate(ctx, sqliteMigrations)
+}
+
+// MigrationStatus lists every schema migration known to this build, in
+// version order, with the time each was applied
+func (r *SQLiteRepository) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return r.migrationStatus(ctx, sqliteMigrations)
+}
+
+func (r *SQLiteRepository) migrate(ctx context.Context, migrations []migration) (int, error) {
+	return r.migrator().migrate(ctx, migrations)
+}
+
+func (r *SQLiteRepository) migrationStatus(ctx context.Context, migrations []migration) ([]MigrationStatus, error) {
+	return r.migrator().migrationStatus(ctx, migrations)
+}
+
+func (r *SQLiteRepository) migrator() *schemaMigrator {
+	return &schemaMigrator{db: r.db, logger: r.logger, placeholder: func(int) string { return "?" }}
+}
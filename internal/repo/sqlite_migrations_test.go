@@ -0,0 +1,183 @@
+//go:build cgo
+
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pubmed-api/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func skipWithoutFTS5(t *testing.T, err error) {
+	t.Helper()
+
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip("go-sqlite3 built without FTS5; run with -tags sqlite_fts5")
+	}
+}
+
+func TestSQLiteRepository_MigrateFresh(t *testing.T) {
+	r, err := NewSQLiteRepository(":memory:", slog.Default())
+	skipWithoutFTS5(t, err)
+	require.NoError(t, err)
+	defer r.Close()
+
+	statuses, err := r.MigrationStatus(context.Background())
+	require.NoError(t, err)
+	require.Len(t, statuses, len(sqliteMigrations))
+	for i, status := range statuses {
+		assert.Equal(t, i+1, status.Version, "migrations are numbered in order")
+		assert.False(t, status.AppliedAt.IsZero())
+	}
+	assert.Zero(t, PendingMigrations(statuses))
+
+	applied, err := r.Migrate(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+}
+
+func TestSQLiteRepository_MigrateExistingDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		// seed inserts an article, one stored without MeSH terms, and a load
+		// history row if the schema has the table
+		seed       string
+		hasHistory bool
+	}{
+		{
+			name:   "baseline",
+			schema: "testdata/schema_baseline.sql",
+			seed: `INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi, search_text) VALUES ('1', 'Aspirin and stroke', '', '[]', 'J1', 2020, '["Aspirin"]', '', 'aspirin and stroke');
+				INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi, search_text) VALUES ('3', 'Statins', '', '[]', 'J1', 2020, 'null', '', 'statins')`,
+		},
+		{
+			name:   "before migrations",
+			schema: "testdata/schema_before_migrations.sql",
+			seed: `INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi) VALUES ('1', 'Aspirin and stroke', '', '[]', 'J1', 2020, '["Aspirin"]', '');
+				INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi) VALUES ('3', 'Statins', '', '[]', 'J1', 2020, 'null', '');
+				INSERT INTO load_history (source, version, fingerprint, loaded_at, accepted, rejected, duplicates, deleted) VALUES ('a.jsonl', 'v1', 'sha256:aa', 1, 1, 0, 0, 0)`,
+			hasHistory: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "pubmed.db")
+			schema, err := os.ReadFile(tt.schema)
+			require.NoError(t, err)
+
+			// Create a database the way initSchema did before migrations existed
+			db, err := sql.Open("sqlite3", dbPath)
+			require.NoError(t, err)
+			_, err = db.Exec(string(schema))
+			skipWithoutFTS5(t, err)
+			require.NoError(t, err)
+			_, err = db.Exec(tt.seed)
+			require.NoError(t, err)
+			require.NoError(t, db.Close())
+
+			r, err := NewSQLiteRepository(dbPath, slog.Default(), WithoutMigrations())
+			require.NoError(t, err)
+			defer r.Close()
+
+			statuses, err := r.MigrationStatus(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, len(sqliteMigrations), PendingMigrations(statuses))
+
+			applied, err := r.Migrate(context.Background())
+			skipWithoutFTS5(t, err)
+			require.NoError(t, err)
+			assert.Equal(t, len(sqliteMigrations), applied)
+
+			// The existing data survives and stays indexed
+			assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin"}))
+			assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"aspirin"}}))
+			assert.Empty(t, searchPMIDs(t, r, &domain.SearchFilters{Query: "null", IncludeMesh: true}))
+			if tt.hasHistory {
+				record, err := r.FindLoad(context.Background(), "a.jsonl")
+				require.NoError(t, err)
+				require.NotNil(t, record)
+				assert.Equal(t, "v1", record.Version)
+			}
+
+			// New and replaced articles are indexed by the migrated schema
+			_, err = r.InsertArticles(context.Background(), []*domain.Article{
+				{PMID: "2", Title: "Aspirin in pregnancy", Journal: "J1"},
+				{PMID: "1", Title: "Aspirin and stroke, revised", Journal: "J1", MeshTerms: []string{"Stroke"}},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"1", "2"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin"}))
+			assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"stroke"}}))
+			assert.Empty(t, searchPMIDs(t, r, &domain.SearchFilters{Mesh: []string{"aspirin"}}))
+		})
+	}
+}
+
+func TestSQLiteRepository_MigrateRejectsUnknownArticlesTable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "pubmed.db")
+
+	db, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE articles (pmid TEXT PRIMARY KEY, body TEXT)")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	r, err := NewSQLiteRepository(dbPath, slog.Default(), WithoutMigrations())
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, err = r.Migrate(context.Background())
+	assert.ErrorContains(t, err, "unrecognized layout")
+
+	statuses, err := r.MigrationStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(sqliteMigrations), PendingMigrations(statuses), "no migration is recorded")
+}
+
+func TestSQLiteRepository_MigrateRollsBackFailedMigration(t *testing.T) {
+	r, err := NewSQLiteRepository(":memory:", slog.Default())
+	skipWithoutFTS5(t, err)
+	require.NoError(t, err)
+	defer r.Close()
+
+	migrations := append(append([]migration{}, sqliteMigrations...), migration{
+		version: len(sqliteMigrations) + 1,
+		name:    "broken",
+		sql:     "CREATE TABLE broken_partial (id INTEGER); ALTER TABLE missing ADD COLUMN x TEXT;",
+	})
+
+	_, err = r.migrate(context.Background(), migrations)
+	assert.ErrorContains(t, err, "broken")
+
+	statuses, err := r.migrationStatus(context.Background(), migrations)
+	require.NoError(t, err)
+	assert.Equal(t, 1, PendingMigrations(statuses))
+
+	var tables int
+	require.NoError(t, r.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'broken_partial'").Scan(&tables))
+	assert.Zero(t, tables, "the failed migration is rolled back")
+}
+
+func TestSQLiteRepository_MigrateRejectsNewerDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "pubmed.db")
+
+	r, err := NewSQLiteRepository(dbPath, slog.Default())
+	skipWithoutFTS5(t, err)
+	require.NoError(t, err)
+	_, err = r.db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (999, 'from the future', 0)")
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = NewSQLiteRepository(dbPath, slog.Default())
+	assert.ErrorContains(t, err, "newer than this build supports")
+}
@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"fmt"
+	"pubmed-api/internal/domain"
+	"strings"
+	"unicode"
+)
+
+// plainQuery turns free text into an AND of words, the meaning of a query
+// that has not been parsed by the service layer. It returns nil when the text
+// contains nothing searchable.
+func plainQuery(text string) domain.QueryNode {
+	var terms []domain.QueryNode
+	for _, word := range strings.Fields(text) {
+		term := &domain.QueryTerm{Field: domain.FieldAll, Text: strings.TrimRight(word, "*")}
+		term.Prefix = term.Text != word
+		if strings.ContainsFunc(term.Text, isTokenRune) {
+			terms = append(terms, term)
+		}
+	}
+
+	switch len(terms) {
+	case 0:
+		return nil
+	case 1:
+		return terms[0]
+	default:
+		return &domain.QueryAnd{Operands: terms}
+	}
+}
+
+// isTokenRune reports whether r is part of a token for the unicode61 tokenizer.
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// ftsColumns maps full-text query fields to FTS5 column filters
+var ftsColumns = map[domain.QueryField]string{
+	domain.FieldAll:           "{title abstract}",
+	domain.FieldTitleAbstract: "{title abstract}",
+	domain.FieldTitle:         "{title}",
+	domain.FieldAbstract:      "{abstract}",
+}
+
+// sqliteQuery compiles query ASTs into FTS5 expressions and SQL conditions
+type sqliteQuery struct {
+	// includeMesh extends untagged terms to the mesh_terms column
+	includeMesh bool
+}
+
+// ftsTerm renders a full-text term as an FTS5 phrase. The text is always
+// quoted so user input can never be interpreted as FTS5 query syntax.
+func (q sqliteQuery) ftsTerm(term *domain.QueryTerm) string {
+	columns := ftsColumns[term.Field]
+	if term.Field == domain.FieldAll && q.includeMesh {
+		columns = "{title abstract mesh_terms}"
+	}
+
+	phrase := columns + ` : "` + strings.ReplaceAll(term.Text, `"`, `""`) + `"`
+	if term.Prefix {
+		phrase += "*"
+	}
+	return phrase
+}
+
+// ftsExpr renders node as a single FTS5 expression. It reports false when the
+// subtree contains anything other than full-text terms.
+func (q sqliteQuery) ftsExpr(node domain.QueryNode) (string, bool) {
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		if !n.Field.IsText() {
+			return "", false
+		}
+		return q.ftsTerm(n), true
+	case *domain.QueryAnd:
+		return q.ftsJoin(n.Operands, " AND ")
+	case *domain.QueryOr:
+		return q.ftsJoin(n.Operands, " OR ")
+	case *domain.QueryNot:
+		include, ok := q.ftsExpr(n.Include)
+		if !ok {
+			return "", false
+		}
+		exclude, ok := q.ftsExpr(n.Exclude)
+		if !ok {
+			return "", false
+		}
+		return "(" + include + " NOT " + exclude + ")", true
+	default:
+		return "", false
+	}
+}
+
+func (q sqliteQuery) ftsJoin(operands []domain.QueryNode, op string) (string, bool) {
+	parts := make([]string, 0, len(operands))
+	for _, operand := range operands {
+		part, ok := q.ftsExpr(operand)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	return "(" + strings.Join(parts, op) + ")", true
+}
+
+// ftsRankExpr ORs together the full-text terms that contribute to a match, so
+// bm25 can score rows of a query that mixes full-text and field conditions.
+// Excluded terms never occur in a matching row and are left out.
+func (q sqliteQuery) ftsRankExpr(node domain.QueryNode) (string, bool) {
+	var terms []string
+	var collect func(domain.QueryNode)
+	collect = func(node domain.QueryNode) {
+		switch n := node.(type) {
+		case *domain.QueryTerm:
+			if n.Field.IsText() {
+				terms = append(terms, q.ftsTerm(n))
+			}
+		case *domain.QueryAnd:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryOr:
+			for _, operand := range n.Operands {
+				collect(operand)
+			}
+		case *domain.QueryNot:
+			collect(n.Include)
+		}
+	}
+	collect(node)
+
+	if len(terms) == 0 {
+		return "", false
+	}
+	return strings.Join(terms, " OR "), true
+}
+
+// sqlWhere compiles node into an SQL condition over the articles table.
+// Full-text subtrees become a single MATCH against articles_fts.
+func (q sqliteQuery) sqlWhere(node domain.QueryNode) (string, []interface{}, error) {
+	if expr, ok := q.ftsExpr(node); ok {
+		return "articles.id IN (SELECT rowid FROM articles_fts WHERE articles_fts MATCH ?)", []interface{}{expr}, nil
+	}
+
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		switch n.Field {
+		case domain.FieldAuthor:
+			return "EXISTS (SELECT 1 FROM json_each(articles.authors) WHERE json_each.value LIKE ?)", []interface{}{"%" + n.Text + "%"}, nil
+		case domain.FieldJournal:
+			return "articles.journal = ? COLLATE NOCASE", []interface{}{n.Text}, nil
+		case domain.FieldMesh:
+			return "articles.id IN (SELECT article_id FROM article_mesh WHERE term = ?)", []interface{}{n.Text}, nil
+		}
+		return "", nil, fmt.Errorf("unsupported query field: %q", n.Field)
+	case *domain.QueryYears:
+		return "articles.pub_year BETWEEN ? AND ?", []interface{}{n.From, n.To}, nil
+	case *domain.QueryAnd:
+		return q.sqlJoin(n.Operands, " AND ")
+	case *domain.QueryOr:
+		return q.sqlJoin(n.Operands, " OR ")
+	case *domain.QueryNot:
+		include, includeArgs, err := q.sqlWhere(n.Include)
+		if err != nil {
+			return "", nil, err
+		}
+		exclude, excludeArgs, err := q.sqlWhere(n.Exclude)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + include + " AND NOT " + exclude + ")", append(includeArgs, excludeArgs...), nil
+	}
+
+	return "", nil, fmt.Errorf("unsupported query node: %T", node)
+}
+
+func (q sqliteQuery) sqlJoin(operands []domain.QueryNode, op string) (string, []interface{}, error) {
+	parts := make([]string, 0, len(operands))
+	var args []interface{}
+	for _, operand := range operands {
+		part, partArgs, err := q.sqlWhere(operand)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+	}
+	return "(" + strings.Join(parts, op) + ")", args, nil
+}
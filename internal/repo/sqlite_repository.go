@@ -13,6 +13,15 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// BM25 column weights for relevance ranking. A hit in the title counts ten
+// times as much as one in the abstract; MeSH indexing sits in between since it
+// is curated but assigned to every article in bulk.
+const (
+	titleRankWeight    = 10.0
+	abstractRankWeight = 1.0
+	meshRankWeight     = 5.0
+)
+
 // SQLiteRepository implements ArticleRepository using SQLite
 type SQLiteRepository struct {
 	db     *sql.DB
@@ -22,50 +31,91 @@ type SQLiteRepository struct {
 // Assert SQLiteRepository implements ArticleRepository
 var _ ArticleRepository = (*SQLiteRepository)(nil)
 
-// NewSQLiteRepository creates a new SQLite repository
-func NewSQLiteRepository(dbPath string, logger *slog.Logger) (*SQLiteRepository, error) {
+// SQLiteOption configures a SQLiteRepository
+type SQLiteOption func(*sqliteOptions)
+
+type sqliteOptions struct {
+	migrate bool
+}
+
+// WithoutMigrations opens the database as it is instead of applying pending
+// schema migrations, e.g. to report or apply them separately
+func WithoutMigrations() SQLiteOption {
+	return func(o *sqliteOptions) {
+		o.migrate = false
+	}
+}
+
+// NewSQLiteRepository creates a new SQLite repository. Pending schema
+// migrations are applied unless WithoutMigrations is given.
+func NewSQLiteRepository(dbPath string, logger *slog.Logger, opts ...SQLiteOption) (*SQLiteRepository, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Every connection to ":memory:" gets its own empty database
+	if dbPath == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
 	repo := &SQLiteRepository{
 		db:     db,
 		logger: logger,
 	}
 
-	if err := repo.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to init schema: %w", err)
+	options := sqliteOptions{migrate: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.migrate {
+		if _, err := repo.Migrate(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to init schema: %w", err)
+		}
 	}
 
 	return repo, nil
 }
 
-// initSchema creates the articles table if it doesn't exist
-func (r *SQLiteRepository) initSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS articles (
-		pmid TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		abstract TEXT,
-		authors TEXT NOT NULL,
-		journal TEXT NOT NULL,
-		pub_year INTEGER,
-		mesh_terms TEXT,
-		doi TEXT,
-		search_text TEXT
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_search_text ON articles(search_text);
-	CREATE INDEX IF NOT EXISTS idx_pub_year ON articles(pub_year);
-	CREATE INDEX IF NOT EXISTS idx_journal ON articles(journal);
-	`
-
-	if _, err := r.db.Exec(query); err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+// deleteChunkSize bounds the number of PMIDs bound to one DELETE statement
+const deleteChunkSize = 500
+
+// DeleteArticles deletes the articles with the given PMIDs in one
+// transaction and returns how many existed. Unknown PMIDs are ignored.
+func (r *SQLiteRepository) DeleteArticles(ctx context.Context, pmids []string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	deleted := 0
+	for start := 0; start < len(pmids); start += deleteChunkSize {
+		chunk := pmids[start:min(start+deleteChunkSize, len(pmids))]
+		args := make([]interface{}, len(chunk))
+		for i, pmid := range chunk {
+			args[i] = pmid
+		}
+
+		// The delete triggers remove the articles from articles_fts and article_mesh
+		result, err := tx.ExecContext(ctx, "DELETE FROM articles WHERE pmid IN ("+placeholders(len(chunk))+")", args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete articles: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to count deleted articles: %w", err)
+		}
+		deleted += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	r.logger.Debug("deleted articles", "requested", len(pmids), "deleted", deleted)
+	return deleted, nil
 }
 
 // LoadData loads articles from a JSONL file
@@ -75,27 +125,57 @@ func (r *SQLiteRepository) LoadData(ctx context.Context, dataPath string) error
 	return nil
 }
 
-// InsertArticles inserts articles into the database
-func (r *SQLiteRepository) InsertArticles(ctx context.Context, articles []*domain.Article) error {
+// InsertArticles inserts articles into the database, replacing existing
+// articles with the same PMID, and returns how many it replaced
+func (r *SQLiteRepository) InsertArticles(ctx context.Context, articles []*domain.Article) (int, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// An upsert does not tell an insert from an update, so the PMIDs already
+	// stored are looked up first, in one query for the whole batch
+	stored, err := storedPMIDs(ctx, tx, articles)
+	if err != nil {
+		return 0, err
+	}
+
+	// Upsert rather than INSERT OR REPLACE: REPLACE deletes the old row without
+	// firing the delete trigger, which would leave stale entries in articles_fts.
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT OR REPLACE INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi, search_text)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO articles (pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(pmid) DO UPDATE SET
+			title = excluded.title,
+			abstract = excluded.abstract,
+			authors = excluded.authors,
+			journal = excluded.journal,
+			pub_year = excluded.pub_year,
+			mesh_terms = excluded.mesh_terms,
+			doi = excluded.doi
 	`)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
+	replaced := 0
 	for _, article := range articles {
+		// A PMID repeated within the batch replaces its earlier record
+		if stored[article.PMID] {
+			replaced++
+		}
+		stored[article.PMID] = true
+
 		authorsJSON, _ := json.Marshal(article.Authors)
-		meshTermsJSON, _ := json.Marshal(article.MeshTerms)
-		searchText := strings.ToLower(article.Title + " " + article.Abstract)
+		// No MeSH terms are stored as an empty array: the full-text index
+		// would take the word of a JSON null for a term
+		meshTerms := article.MeshTerms
+		if meshTerms == nil {
+			meshTerms = []string{}
+		}
+		meshTermsJSON, _ := json.Marshal(meshTerms)
 
 		_, err := stmt.ExecContext(ctx,
 			article.PMID,
@@ -106,30 +186,118 @@ func (r *SQLiteRepository) InsertArticles(ctx context.Context, articles []*domai
 			article.PubYear,
 			string(meshTermsJSON),
 			article.DOI,
-			searchText,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to insert article %s: %w", article.PMID, err)
+			return 0, fmt.Errorf("failed to insert article %s: %w", article.PMID, err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	r.logger.Info("loaded articles", "count", len(articles))
-	return nil
+	r.logger.Debug("loaded articles", "count", len(articles), "replaced", replaced)
+	return replaced, nil
+}
+
+// storedPMIDs returns the set of the PMIDs of articles that are already
+// stored
+func storedPMIDs(ctx context.Context, tx *sql.Tx, articles []*domain.Article) (map[string]bool, error) {
+	stored := make(map[string]bool, len(articles))
+	if len(articles) == 0 {
+		return stored, nil
+	}
+
+	pmids := make([]string, len(articles))
+	for i, article := range articles {
+		pmids[i] = article.PMID
+	}
+	pmidsJSON, err := json.Marshal(pmids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pmids: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT pmid FROM articles WHERE pmid IN (SELECT value FROM json_each(?))", string(pmidsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stored articles: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pmid string
+		if err := rows.Scan(&pmid); err != nil {
+			return nil, fmt.Errorf("failed to scan pmid: %w", err)
+		}
+		stored[pmid] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+	return stored, nil
 }
 
 // FindByID retrieves an article by its PubMed ID
 func (r *SQLiteRepository) FindByID(ctx context.Context, pmid string) (*domain.Article, error) {
-	query := `SELECT pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi
-		FROM articles WHERE pmid = ?`
+	row := r.db.QueryRowContext(ctx, "SELECT "+sqliteArticleColumns+" FROM articles WHERE pmid = ?", pmid)
+
+	article, err := scanSQLiteArticle(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("article not found: %s", pmid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query article: %w", err)
+	}
+
+	return article, nil
+}
+
+// FindByIDs retrieves the articles with the given PubMed IDs in one query.
+// The PMIDs are bound as a single JSON array, so their number is not bounded
+// by SQLite's limit on variables.
+func (r *SQLiteRepository) FindByIDs(ctx context.Context, pmids []string) ([]*domain.Article, error) {
+	if len(pmids) == 0 {
+		return nil, nil
+	}
+
+	pmidsJSON, err := json.Marshal(pmids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pmids: %w", err)
+	}
 
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+sqliteArticleColumns+" FROM articles WHERE pmid IN (SELECT value FROM json_each(?))", string(pmidsJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query articles: %w", err)
+	}
+	defer rows.Close()
+
+	var articles []*domain.Article
+	for rows.Next() {
+		article, err := scanSQLiteArticle(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		articles = append(articles, article)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return articles, nil
+}
+
+// sqliteArticleColumns selects an article in the order scanned by
+// scanSQLiteArticle
+const sqliteArticleColumns = "pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi"
+
+// scanSQLiteArticle scans the sqliteArticleColumns of a row, followed by any
+// extra destinations
+func scanSQLiteArticle(scan func(dest ...interface{}) error, extra ...interface{}) (*domain.Article, error) {
 	var article domain.Article
 	var authorsJSON, meshTermsJSON string
 
-	err := r.db.QueryRowContext(ctx, query, pmid).Scan(
+	dest := append([]interface{}{
 		&article.PMID,
 		&article.Title,
 		&article.Abstract,
@@ -138,12 +306,9 @@ func (r *SQLiteRepository) FindByID(ctx context.Context, pmid string) (*domain.A
 		&article.PubYear,
 		&meshTermsJSON,
 		&article.DOI,
-	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("article not found: %s", pmid)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("failed to query article: %w", err)
+	}, extra...)
+	if err := scan(dest...); err != nil {
+		return nil, err
 	}
 
 	if err := json.Unmarshal([]byte(authorsJSON), &article.Authors); err != nil {
@@ -161,153 +326,325 @@ func (r *SQLiteRepository) FindByID(ctx context.Context, pmid string) (*domain.A
 func (r *SQLiteRepository) Search(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
 	startTime := time.Now()
 
-	// Build WHERE clause
-	whereClauses := []string{}
-	args := []interface{}{}
-
-	if filters.Query != "" {
-		whereClauses = append(whereClauses, "search_text LIKE ?")
-		args = append(args, "%"+strings.ToLower(filters.Query)+"%")
+	clauses, err := buildSearchClauses(filters)
+	if err != nil {
+		return nil, err
 	}
+	fromClause, whereClause := clauses.from, clauses.where
+	args := clauses.args()
 
-	if filters.Year != nil {
-		whereClauses = append(whereClauses, "pub_year = ?")
-		args = append(args, *filters.Year)
+	// Keyset pages are not counted; they start after the cursor instead of
+	// at an offset
+	var total int
+	var offset int
+	if filters.After == nil {
+		countQuery := "SELECT COUNT(*) FROM " + fromClause + " " + whereClause
+		if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("failed to count articles: %w", err)
+		}
+		offset = (filters.Page - 1) * filters.PageSize
+	} else {
+		whereClause = clauses.and(keysetCondition(filters.After, filters.Sort, clauses.score, func(value interface{}) string {
+			args = append(args, value)
+			return "?"
+		}))
 	}
 
-	if filters.Journal != "" {
-		whereClauses = append(whereClauses, "journal = ?")
-		args = append(args, filters.Journal)
-	}
+	query := fmt.Sprintf(`
+		SELECT %s, %s AS score
+		FROM %s %s ORDER BY %s LIMIT ? OFFSET ?
+	`, sqliteArticleColumns, clauses.score, fromClause, whereClause, searchOrderBy(filters.Sort))
 
-	if filters.Author != "" {
-		whereClauses = append(whereClauses, "authors LIKE ?")
-		args = append(args, "%"+filters.Author+"%")
+	args = append(args, pageLimit(filters), offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query articles: %w", err)
 	}
+	defer rows.Close()
 
-	whereClause := ""
-	if len(whereClauses) > 0 {
-		whereClause = "WHERE " + strings.Join(whereClauses, " AND ")
+	var hits []*domain.SearchHit
+	for rows.Next() {
+		var score float64
+		article, err := scanSQLiteArticle(rows.Scan, &score)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan article: %w", err)
+		}
+		hits = append(hits, &domain.SearchHit{Article: article, Score: score})
 	}
 
-	// Get total count
-	countQuery := "SELECT COUNT(*) FROM articles " + whereClause
-	var total int
-	if err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, fmt.Errorf("failed to count articles: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
 	}
 
-	// Build ORDER BY clause
-	orderBy := "pmid ASC"
-	switch filters.Sort {
-	case "year_desc":
-		orderBy = "pub_year DESC, pmid ASC"
-	case "year_asc":
-		orderBy = "pub_year ASC, pmid ASC"
-	case "relevance":
-		// Naive relevance: prioritize articles where query appears in title
-		if filters.Query != "" {
-			orderBy = fmt.Sprintf("CASE WHEN title LIKE '%%%s%%' THEN 1 ELSE 2 END, pmid ASC", strings.ToLower(filters.Query))
+	hits, next := finishPage(filters, hits, total)
+
+	var facets map[string][]domain.FacetCount
+	if len(filters.Facets) > 0 {
+		facets = make(map[string][]domain.FacetCount, len(filters.Facets))
+		for _, facet := range filters.Facets {
+			counts, err := r.countFacet(ctx, clauses, facet)
+			if err != nil {
+				return nil, err
+			}
+			facets[facet] = counts
 		}
 	}
 
-	// Build pagination
-	offset := (filters.Page - 1) * filters.PageSize
-	limit := filters.PageSize
+	tookMs := time.Since(startTime).Milliseconds()
 
-	query := fmt.Sprintf(`
-		SELECT pmid, title, abstract, authors, journal, pub_year, mesh_terms, doi
-		FROM articles %s ORDER BY %s LIMIT ? OFFSET ?
-	`, whereClause, orderBy)
+	result := &domain.SearchResult{
+		Items:      hits,
+		Page:       filters.Page,
+		PageSize:   filters.PageSize,
+		TookMs:     tookMs,
+		NextCursor: next,
+		Facets:     facets,
+	}
+	if filters.After == nil {
+		result.Total = &total
+	}
+	return result, nil
+}
 
-	args = append(args, limit, offset)
+// facetLimit is the number of values returned per facet
+const facetLimit = 10
+
+// countFacet counts the most frequent values of a facet over the articles
+// selected by clauses. Multi-valued fields are counted once per article.
+func (r *SQLiteRepository) countFacet(ctx context.Context, clauses *searchClauses, facet string) ([]domain.FacetCount, error) {
+	var value, from, where string
+	switch facet {
+	case domain.FacetJournal:
+		value, from, where = "journal", clauses.from, clauses.where
+	case domain.FacetYear:
+		value, from, where = "CAST(pub_year AS TEXT)", clauses.from, clauses.and("pub_year IS NOT NULL")
+	case domain.FacetAuthor:
+		value, from, where = "json_each.value", clauses.from+", json_each(articles.authors)", clauses.where
+	case domain.FacetMesh:
+		value, from, where = "article_mesh.term", clauses.from+" JOIN article_mesh ON article_mesh.article_id = articles.id", clauses.where
+	default:
+		return nil, fmt.Errorf("unknown facet: %s", facet)
+	}
 
-	rows, err := r.db.QueryContext(ctx, query, args...)
+	query := fmt.Sprintf(`
+		SELECT %s AS value, COUNT(DISTINCT articles.id) AS count
+		FROM %s %s
+		GROUP BY value
+		ORDER BY count DESC, value ASC
+		LIMIT ?
+	`, value, from, where)
+
+	rows, err := r.db.QueryContext(ctx, query, append(clauses.args(), facetLimit)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query articles: %w", err)
+		return nil, fmt.Errorf("failed to query %s facet: %w", facet, err)
 	}
 	defer rows.Close()
 
-	var articles []*domain.Article
+	counts := []domain.FacetCount{}
 	for rows.Next() {
-		var article domain.Article
-		var authorsJSON, meshTermsJSON string
-
-		if err := rows.Scan(
-			&article.PMID,
-			&article.Title,
-			&article.Abstract,
-			&authorsJSON,
-			&article.Journal,
-			&article.PubYear,
-			&meshTermsJSON,
-			&article.DOI,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan article: %w", err)
+		var fc domain.FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan %s facet: %w", facet, err)
+		}
+		counts = append(counts, fc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate %s facet: %w", facet, err)
+	}
+
+	return counts, nil
+}
+
+// searchClauses holds the SQL fragments shared by every query over a filtered
+// set of articles
+type searchClauses struct {
+	// from is the articles table, joined to the bm25 ranking when there is
+	// a full-text query
+	from     string
+	fromArgs []interface{}
+	// where includes the "WHERE" keyword, or is empty
+	where     string
+	whereArgs []interface{}
+	// score is the relevance score column expression
+	score string
+}
+
+// and returns the WHERE clause with an extra condition ANDed on
+func (c *searchClauses) and(condition string) string {
+	if c.where == "" {
+		return "WHERE " + condition
+	}
+	return c.where + " AND " + condition
+}
+
+// args returns the placeholder arguments in the order they appear in the SQL
+func (c *searchClauses) args() []interface{} {
+	return append(append([]interface{}{}, c.fromArgs...), c.whereArgs...)
+}
+
+// buildSearchClauses compiles the filters into FROM and WHERE clauses.
+//
+// The full-text match is a join so that bm25() can score each row. When the
+// whole query is full-text it is matched once and the join also filters;
+// otherwise the compiled query filters and a LEFT JOIN scores the rows by
+// their full-text terms. Without a query every row scores 0.
+func buildSearchClauses(filters *domain.SearchFilters) (*searchClauses, error) {
+	c := &searchClauses{from: "articles", score: "0"}
+	whereClauses := []string{}
+
+	expr := filters.Expr
+	if expr == nil && filters.Query != "" {
+		expr = plainQuery(filters.Query)
+		if expr == nil {
+			// Nothing in the query can match an indexed token
+			whereClauses = append(whereClauses, "0")
 		}
+	}
 
-		if err := json.Unmarshal([]byte(authorsJSON), &article.Authors); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal authors: %w", err)
+	if expr != nil {
+		q := sqliteQuery{includeMesh: filters.IncludeMesh}
+		rankedJoin := "JOIN"
+		rankExpr, ranked := q.ftsExpr(expr)
+		if !ranked {
+			where, whereArgs, err := q.sqlWhere(expr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compile query: %w", err)
+			}
+			whereClauses = append(whereClauses, where)
+			c.whereArgs = append(c.whereArgs, whereArgs...)
+
+			rankedJoin = "LEFT JOIN"
+			rankExpr, ranked = q.ftsRankExpr(expr)
 		}
 
-		if err := json.Unmarshal([]byte(meshTermsJSON), &article.MeshTerms); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal mesh terms: %w", err)
+		if ranked {
+			c.from = fmt.Sprintf(`articles %s (
+				SELECT rowid, -bm25(articles_fts, %g, %g, %g) AS score
+				FROM articles_fts WHERE articles_fts MATCH ?
+			) AS ranked ON ranked.rowid = articles.id`, rankedJoin, titleRankWeight, abstractRankWeight, meshRankWeight)
+			c.fromArgs = append(c.fromArgs, rankExpr)
+			c.score = "COALESCE(ranked.score, 0)"
 		}
+	}
 
-		articles = append(articles, &article)
+	if filters.YearFrom != nil {
+		whereClauses = append(whereClauses, "pub_year >= ?")
+		c.whereArgs = append(c.whereArgs, *filters.YearFrom)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	if filters.YearTo != nil {
+		whereClauses = append(whereClauses, "pub_year <= ?")
+		c.whereArgs = append(c.whereArgs, *filters.YearTo)
 	}
 
-	tookMs := time.Since(startTime).Milliseconds()
+	if len(filters.Journals) > 0 {
+		whereClauses = append(whereClauses, "journal IN ("+placeholders(len(filters.Journals))+")")
+		for _, journal := range filters.Journals {
+			c.whereArgs = append(c.whereArgs, journal)
+		}
+	}
 
-	return &domain.SearchResult{
-		Items:    articles,
-		Page:     filters.Page,
-		PageSize: filters.PageSize,
-		Total:    total,
-		TookMs:   tookMs,
-	}, nil
+	if len(filters.Authors) > 0 {
+		// One EXISTS per author when all are required, a single one otherwise
+		authorMatch := "EXISTS (SELECT 1 FROM json_each(articles.authors) WHERE json_each.value LIKE ?)"
+		authorClauses := make([]string, 0, len(filters.Authors))
+		for _, author := range filters.Authors {
+			authorClauses = append(authorClauses, authorMatch)
+			c.whereArgs = append(c.whereArgs, "%"+author+"%")
+		}
+
+		if filters.AuthorOperator == domain.OperatorOr {
+			whereClauses = append(whereClauses, "("+strings.Join(authorClauses, " OR ")+")")
+		} else {
+			whereClauses = append(whereClauses, strings.Join(authorClauses, " AND "))
+		}
+	}
+
+	if len(filters.Mesh) > 0 {
+		// An exploded term matches itself or any of its narrower terms
+		meshMatch := "articles.id IN (SELECT article_id FROM article_mesh WHERE term IN (%s))"
+		if filters.MeshOperator == domain.OperatorOr {
+			var terms []string
+			for _, term := range filters.Mesh {
+				terms = append(terms, term)
+				terms = append(terms, filters.MeshExpansions[term]...)
+			}
+			whereClauses = append(whereClauses, fmt.Sprintf(meshMatch, placeholders(len(terms))))
+			for _, term := range terms {
+				c.whereArgs = append(c.whereArgs, term)
+			}
+		} else {
+			for _, term := range filters.Mesh {
+				expansions := filters.MeshExpansions[term]
+				whereClauses = append(whereClauses, fmt.Sprintf(meshMatch, placeholders(1+len(expansions))))
+				c.whereArgs = append(c.whereArgs, term)
+				for _, narrower := range expansions {
+					c.whereArgs = append(c.whereArgs, narrower)
+				}
+			}
+		}
+	}
+
+	if len(whereClauses) > 0 {
+		c.where = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	return c, nil
+}
+
+// placeholders returns n comma-separated SQL placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
 }
 
-// GetStats returns aggregate statistics
-func (r *SQLiteRepository) GetStats(ctx context.Context) (*domain.Stats, error) {
+// GetStats returns aggregate statistics over the articles matching filters
+func (r *SQLiteRepository) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	clauses, err := buildSearchClauses(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM " + clauses.from + " " + clauses.where
+	if err := r.db.QueryRowContext(ctx, countQuery, clauses.args()...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count articles: %w", err)
+	}
+
 	// Top journals
-	journalQuery := `
+	journalQuery := fmt.Sprintf(`
 		SELECT journal, COUNT(*) as count
-		FROM articles
+		FROM %s %s
 		GROUP BY journal
-		ORDER BY count DESC
-		LIMIT 5
-	`
+		ORDER BY count DESC, journal ASC
+		LIMIT ?
+	`, clauses.from, clauses.where)
 
-	rows, err := r.db.QueryContext(ctx, journalQuery)
+	rows, err := r.db.QueryContext(ctx, journalQuery, append(clauses.args(), topJournals)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query top journals: %w", err)
 	}
 	defer rows.Close()
 
-	var topJournals []domain.JournalCount
+	journalCounts := []domain.JournalCount{}
 	for rows.Next() {
 		var jc domain.JournalCount
 		if err := rows.Scan(&jc.Journal, &jc.Count); err != nil {
 			return nil, fmt.Errorf("failed to scan journal count: %w", err)
 		}
-		topJournals = append(topJournals, jc)
+		journalCounts = append(journalCounts, jc)
 	}
 
 	// Year histogram
-	yearQuery := `
+	yearQuery := fmt.Sprintf(`
 		SELECT pub_year, COUNT(*) as count
-		FROM articles
-		WHERE pub_year IS NOT NULL
+		FROM %s %s
 		GROUP BY pub_year
 		ORDER BY pub_year
-	`
+	`, clauses.from, clauses.and("pub_year IS NOT NULL"))
 
-	rows, err = r.db.QueryContext(ctx, yearQuery)
+	rows, err = r.db.QueryContext(ctx, yearQuery, clauses.args()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query year histogram: %w", err)
 	}
@@ -323,7 +660,8 @@ func (r *SQLiteRepository) GetStats(ctx context.Context) (*domain.Stats, error)
 	}
 
 	return &domain.Stats{
-		TopJournals:   topJournals,
+		Total:         total,
+		TopJournals:   journalCounts,
 		YearHistogram: yearHistogram,
 	}, nil
 }
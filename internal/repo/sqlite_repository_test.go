@@ -0,0 +1,103 @@
+//go:build cgo
+
+package repo
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"pubmed-api/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSQLiteRepository opens an in-memory repository seeded with articles.
+// The test is skipped when go-sqlite3 was built without FTS5.
+func newTestSQLiteRepository(t *testing.T, articles ...*domain.Article) *SQLiteRepository {
+	t.Helper()
+
+	r, err := NewSQLiteRepository(":memory:", slog.Default())
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip("go-sqlite3 built without FTS5; run with -tags sqlite_fts5")
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Close() })
+
+	_, err = r.InsertArticles(context.Background(), articles)
+	require.NoError(t, err)
+	return r
+}
+
+func TestSQLiteRepository_Conformance(t *testing.T) {
+	testArticleRepository(t, func(t *testing.T, articles ...*domain.Article) ArticleRepository {
+		return newTestSQLiteRepository(t, articles...)
+	})
+}
+
+func TestSQLiteRepository_PersistsAcrossRestarts(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "pubmed.db")
+
+	r, err := NewSQLiteRepository(dbPath, slog.Default())
+	if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+		t.Skip("go-sqlite3 built without FTS5; run with -tags sqlite_fts5")
+	}
+	require.NoError(t, err)
+	_, err = r.InsertArticles(context.Background(), []*domain.Article{{PMID: "1", Title: "Aspirin and stroke", Journal: "J1"}})
+	require.NoError(t, err)
+	require.NoError(t, r.RecordLoad(context.Background(), &domain.LoadRecord{Source: "a.jsonl", Version: "v1", LoadedAt: time.Now()}))
+	require.NoError(t, r.Close())
+
+	// Reopening keeps the data, and the schema is created only once
+	r, err = NewSQLiteRepository(dbPath, slog.Default())
+	require.NoError(t, err)
+	defer r.Close()
+
+	assert.Equal(t, []string{"1"}, searchPMIDs(t, r, &domain.SearchFilters{Query: "aspirin"}))
+	record, err := r.FindLoad(context.Background(), "a.jsonl")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, "v1", record.Version)
+}
+
+func TestSQLiteRepository_ScoresMatchMemoryRepository(t *testing.T) {
+	articles := []*domain.Article{
+		{PMID: "1", Title: "Renal outcomes", Abstract: "Patients took ibuprofen daily; ibuprofen dosing varied.", Journal: "J1", MeshTerms: []string{"Kidney"}},
+		{PMID: "2", Title: "Ibuprofen and renal outcomes in a long cohort of older adults", Abstract: "A cohort study.", Journal: "J1", MeshTerms: []string{"Aged", "Cohort Studies"}},
+		{PMID: "3", Title: "Ibuprofen", Abstract: "Renal injury after ibuprofen.", Journal: "J2", MeshTerms: []string{"Ibuprofen", "Acute Kidney Injury"}},
+		{PMID: "4", Title: "Aspirin", Abstract: "No NSAIDs here.", Journal: "J2", MeshTerms: []string{"Aspirin"}},
+	}
+	// Terms in fewer than half of the articles have a positive IDF
+	for i := 5; i <= 12; i++ {
+		articles = append(articles, &domain.Article{PMID: strconv.Itoa(i), Title: "Statins", Abstract: "Cholesterol lowering.", Journal: "J3", MeshTerms: []string{"Hydroxymethylglutaryl-CoA Reductase Inhibitors"}})
+	}
+	sqliteRepo := newTestSQLiteRepository(t, articles...)
+	memoryRepo := newTestMemoryRepository(t, articles...)
+
+	for _, filters := range []*domain.SearchFilters{
+		{Query: "ibuprofen"},
+		{Query: "ibuprofen renal"},
+		{Query: "kidney", IncludeMesh: true},
+		{Expr: &domain.QueryOr{Operands: []domain.QueryNode{
+			&domain.QueryTerm{Text: "renal outcomes", Field: domain.FieldTitle},
+			&domain.QueryTerm{Text: "ibup", Field: domain.FieldAbstract, Prefix: true},
+		}}},
+	} {
+		filters.Sort, filters.Page, filters.PageSize = "relevance", 1, 10
+		want, err := sqliteRepo.Search(context.Background(), filters)
+		require.NoError(t, err)
+		got, err := memoryRepo.Search(context.Background(), filters)
+		require.NoError(t, err)
+
+		require.Len(t, got.Items, len(want.Items), filters.Query)
+		for i := range want.Items {
+			assert.Equal(t, want.Items[i].PMID, got.Items[i].PMID, filters.Query)
+			assert.InDelta(t, want.Items[i].Score, got.Items[i].Score, 1e-9, filters.Query)
+		}
+	}
+}
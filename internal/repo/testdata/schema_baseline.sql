@@ -0,0 +1,18 @@
+-- Schema created by the first initSchema, which keyed articles by pmid and
+-- matched searches against search_text, used to test upgrading a database
+-- from before the full-text index
+CREATE TABLE IF NOT EXISTS articles (
+	pmid TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	abstract TEXT,
+	authors TEXT NOT NULL,
+	journal TEXT NOT NULL,
+	pub_year INTEGER,
+	mesh_terms TEXT,
+	doi TEXT,
+	search_text TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_text ON articles(search_text);
+CREATE INDEX IF NOT EXISTS idx_pub_year ON articles(pub_year);
+CREATE INDEX IF NOT EXISTS idx_journal ON articles(journal);
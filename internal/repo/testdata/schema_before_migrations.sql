@@ -0,0 +1,77 @@
+-- Schema created by initSchema before versioned migrations were introduced,
+-- used to test upgrading an existing database
+CREATE TABLE IF NOT EXISTS articles (
+	id INTEGER PRIMARY KEY,
+	pmid TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	abstract TEXT,
+	authors TEXT NOT NULL,
+	journal TEXT NOT NULL,
+	pub_year INTEGER,
+	mesh_terms TEXT,
+	doi TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_pub_year ON articles(pub_year);
+CREATE INDEX IF NOT EXISTS idx_journal ON articles(journal);
+DROP INDEX IF EXISTS idx_search_text;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS articles_fts USING fts5(
+	title,
+	abstract,
+	mesh_terms,
+	content = 'articles',
+	content_rowid = 'id',
+	tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TABLE IF NOT EXISTS article_mesh (
+	article_id INTEGER NOT NULL REFERENCES articles(id),
+	term TEXT NOT NULL COLLATE NOCASE,
+	PRIMARY KEY (article_id, term)
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_mesh_term ON article_mesh(term, article_id);
+
+CREATE TRIGGER IF NOT EXISTS article_mesh_insert AFTER INSERT ON articles BEGIN
+	INSERT OR IGNORE INTO article_mesh(article_id, term)
+	SELECT new.id, value FROM json_each(new.mesh_terms) WHERE type = 'text';
+END;
+
+CREATE TRIGGER IF NOT EXISTS article_mesh_delete AFTER DELETE ON articles BEGIN
+	DELETE FROM article_mesh WHERE article_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS article_mesh_update AFTER UPDATE OF mesh_terms ON articles BEGIN
+	DELETE FROM article_mesh WHERE article_id = old.id;
+	INSERT OR IGNORE INTO article_mesh(article_id, term)
+	SELECT new.id, value FROM json_each(new.mesh_terms) WHERE type = 'text';
+END;
+
+CREATE TRIGGER IF NOT EXISTS articles_fts_insert AFTER INSERT ON articles BEGIN
+	INSERT INTO articles_fts(rowid, title, abstract, mesh_terms)
+	VALUES (new.id, new.title, new.abstract, new.mesh_terms);
+END;
+
+CREATE TRIGGER IF NOT EXISTS articles_fts_delete AFTER DELETE ON articles BEGIN
+	INSERT INTO articles_fts(articles_fts, rowid, title, abstract, mesh_terms)
+	VALUES ('delete', old.id, old.title, old.abstract, old.mesh_terms);
+END;
+
+CREATE TRIGGER IF NOT EXISTS articles_fts_update AFTER UPDATE ON articles BEGIN
+	INSERT INTO articles_fts(articles_fts, rowid, title, abstract, mesh_terms)
+	VALUES ('delete', old.id, old.title, old.abstract, old.mesh_terms);
+	INSERT INTO articles_fts(rowid, title, abstract, mesh_terms)
+	VALUES (new.id, new.title, new.abstract, new.mesh_terms);
+END;
+
+CREATE TABLE IF NOT EXISTS load_history (
+	source TEXT PRIMARY KEY,
+	version TEXT NOT NULL DEFAULT '',
+	fingerprint TEXT NOT NULL,
+	loaded_at INTEGER NOT NULL,
+	accepted INTEGER NOT NULL,
+	rejected INTEGER NOT NULL,
+	duplicates INTEGER NOT NULL,
+	deleted INTEGER NOT NULL
+);
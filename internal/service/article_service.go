@@ -2,20 +2,49 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"pubmed-api/internal/domain"
+	"pubmed-api/internal/mesh"
 	"pubmed-api/internal/repo"
 	"strconv"
+	"strings"
 )
 
+// ErrInvalidFilter is returned (wrapped) when a search parameter is invalid
+var ErrInvalidFilter = errors.New("invalid filter")
+
 // ArticleService handles business logic for articles
 type ArticleService struct {
-	repo repo.ArticleRepository
+	repo       repo.ArticleRepository
+	meshTree   *mesh.Tree
+	loadReport *domain.LoadReport
+}
+
+// Option configures an ArticleService
+type Option func(*ArticleService)
+
+// WithMeshTree enables mesh_explode using the given MeSH hierarchy
+func WithMeshTree(tree *mesh.Tree) Option {
+	return func(s *ArticleService) {
+		s.meshTree = tree
+	}
+}
+
+// WithLoadReport makes the report of the startup data load available
+func WithLoadReport(report *domain.LoadReport) Option {
+	return func(s *ArticleService) {
+		s.loadReport = report
+	}
 }
 
 // NewArticleService creates a new article service
-func NewArticleService(repo repo.ArticleRepository) *ArticleService {
-	return &ArticleService{repo: repo}
+func NewArticleService(repo repo.ArticleRepository, opts ...Option) *ArticleService {
+	s := &ArticleService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // GetArticle retrieves an article by its PubMed ID
@@ -27,6 +56,64 @@ func (s *ArticleService) GetArticle(ctx context.Context, pmid string) (*domain.A
 	return s.repo.FindByID(ctx, pmid)
 }
 
+// MaxBatchPMIDs bounds the number of distinct PMIDs of a batch lookup
+const MaxBatchPMIDs = 1000
+
+// BatchGetArticles retrieves the articles with the given PubMed IDs with a
+// single repository query. Found articles are returned once each, in the
+// order of their first request, and the other PMIDs are listed as missing.
+func (s *ArticleService) BatchGetArticles(ctx context.Context, pmids []string) (*domain.BatchGetResult, error) {
+	unique := make([]string, 0, len(pmids))
+	seen := make(map[string]bool, len(pmids))
+	for _, pmid := range pmids {
+		pmid = strings.TrimSpace(pmid)
+		if pmid != "" && !seen[pmid] {
+			seen[pmid] = true
+			unique = append(unique, pmid)
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("%w: at least one pmid is required", ErrInvalidFilter)
+	}
+	if len(unique) > MaxBatchPMIDs {
+		return nil, fmt.Errorf("%w: at most %d pmids can be looked up at once, got %d", ErrInvalidFilter, MaxBatchPMIDs, len(unique))
+	}
+
+	articles, err := s.repo.FindByIDs(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	byPMID := make(map[string]*domain.Article, len(articles))
+	for _, article := range articles {
+		byPMID[article.PMID] = article
+	}
+
+	result := &domain.BatchGetResult{
+		Articles: make([]*domain.Article, 0, len(articles)),
+		Missing:  []string{},
+	}
+	for _, pmid := range unique {
+		if article, ok := byPMID[pmid]; ok {
+			result.Articles = append(result.Articles, article)
+		} else {
+			result.Missing = append(result.Missing, pmid)
+		}
+	}
+	return result, nil
+}
+
+// ParsePMIDs parses the pmid query parameter: pmid=1,2 and pmid=1&pmid=2 are
+// equivalent. ok is false when the parameter is absent.
+func ParsePMIDs(queryParams map[string][]string) (pmids []string, ok bool) {
+	values, ok := queryParams["pmid"]
+	for _, value := range values {
+		pmids = append(pmids, strings.Split(value, ",")...)
+	}
+	return pmids, ok
+}
+
 // SearchArticles performs a search with filters, pagination, and sorting
 func (s *ArticleService) SearchArticles(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
 	// Validate and normalize filters
@@ -42,6 +129,58 @@ func (s *ArticleService) SearchArticles(ctx context.Context, filters *domain.Sea
 		filters.PageSize = 50
 	}
 
+	if err := s.prepareSearch(filters); err != nil {
+		return nil, err
+	}
+
+	return s.repo.Search(ctx, filters)
+}
+
+// exportBatchSize is the number of articles an export reads from the
+// repository at a time
+const exportBatchSize = 500
+
+// ExportArticles calls fn with every article matching filters, in sort order,
+// ignoring pagination and facets. Articles are read in keyset pages, so memory
+// use does not depend on how many match; a cursor in filters resumes an
+// export after it. The export stops at the first error from fn and when ctx
+// is done.
+func (s *ArticleService) ExportArticles(ctx context.Context, filters *domain.SearchFilters, fn func(hit *domain.SearchHit) error) error {
+	filters.Page = 1
+	filters.PageSize = exportBatchSize
+	filters.Facets = nil
+
+	if err := s.prepareSearch(filters); err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := s.repo.Search(ctx, filters)
+		if err != nil {
+			return err
+		}
+
+		for _, hit := range result.Items {
+			if err := fn(hit); err != nil {
+				return err
+			}
+		}
+
+		if result.NextCursor == nil {
+			return nil
+		}
+		filters.After = result.NextCursor
+		filters.Page = 0
+	}
+}
+
+// prepareSearch validates and normalizes the sort, cursor and facets of a
+// search, then the filters it shares with stats
+func (s *ArticleService) prepareSearch(filters *domain.SearchFilters) error {
 	if filters.Sort == "" {
 		filters.Sort = "relevance"
 	}
@@ -56,12 +195,128 @@ func (s *ArticleService) SearchArticles(ctx context.Context, filters *domain.Sea
 		filters.Sort = "relevance"
 	}
 
-	return s.repo.Search(ctx, filters)
+	// A cursor replaces the page number and only makes sense for the sort
+	// order it was issued for
+	if filters.Cursor != "" {
+		var after domain.Cursor
+		if err := after.UnmarshalText([]byte(filters.Cursor)); err != nil {
+			return fmt.Errorf("%w: malformed cursor", ErrInvalidFilter)
+		}
+		if after.Sort != filters.Sort {
+			return fmt.Errorf("%w: cursor was issued for sort %q, not %q", ErrInvalidFilter, after.Sort, filters.Sort)
+		}
+		filters.After = &after
+		filters.Page = 0
+	}
+
+	validFacets := map[string]bool{
+		domain.FacetJournal: true,
+		domain.FacetYear:    true,
+		domain.FacetMesh:    true,
+		domain.FacetAuthor:  true,
+	}
+	for _, facet := range filters.Facets {
+		if !validFacets[facet] {
+			return fmt.Errorf("%w: unknown facet %q", ErrInvalidFilter, facet)
+		}
+	}
+
+	return s.prepareFilters(filters)
+}
+
+// ErrNoLoadReport is returned when no data load has been reported
+var ErrNoLoadReport = errors.New("no load report available")
+
+// GetLoadReport returns the report of the startup data load
+func (s *ArticleService) GetLoadReport(ctx context.Context) (*domain.LoadReport, error) {
+	if s.loadReport == nil {
+		return nil, ErrNoLoadReport
+	}
+	return s.loadReport, nil
 }
 
-// GetStats returns aggregate statistics
-func (s *ArticleService) GetStats(ctx context.Context) (*domain.Stats, error) {
-	return s.repo.GetStats(ctx)
+// DefaultTopJournals and MaxTopJournals bound the journal list in stats
+const (
+	DefaultTopJournals = 5
+	MaxTopJournals     = 100
+)
+
+// GetStats returns aggregate statistics over the articles matching filters.
+// topJournals defaults to DefaultTopJournals and is capped at MaxTopJournals.
+func (s *ArticleService) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	if topJournals < 1 {
+		topJournals = DefaultTopJournals
+	}
+	if topJournals > MaxTopJournals {
+		topJournals = MaxTopJournals
+	}
+
+	if err := s.prepareFilters(filters); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetStats(ctx, filters, topJournals)
+}
+
+// prepareFilters validates the filters shared by search and stats, parses
+// the query into filters.Expr and expands exploded MeSH terms
+func (s *ArticleService) prepareFilters(filters *domain.SearchFilters) error {
+	if filters.YearFrom != nil && filters.YearTo != nil && *filters.YearFrom > *filters.YearTo {
+		return fmt.Errorf("%w: year_from %d is after year_to %d", ErrInvalidFilter, *filters.YearFrom, *filters.YearTo)
+	}
+
+	if err := normalizeOperator(&filters.AuthorOperator, "author_op"); err != nil {
+		return err
+	}
+
+	if err := normalizeOperator(&filters.MeshOperator, "mesh_op"); err != nil {
+		return err
+	}
+
+	if filters.MeshExplode && len(filters.Mesh) > 0 {
+		if s.meshTree == nil {
+			return fmt.Errorf("%w: mesh_explode is unavailable because no MeSH tree is loaded", ErrInvalidFilter)
+		}
+		filters.MeshExpansions = make(map[string][]string, len(filters.Mesh))
+		for _, term := range filters.Mesh {
+			if descendants := s.meshTree.Descendants(term); len(descendants) > 0 {
+				filters.MeshExpansions[term] = descendants
+			}
+		}
+	}
+
+	if filters.Query != "" {
+		expr, err := ParseQuery(filters.Query)
+		if err != nil {
+			return err
+		}
+		filters.Expr = expr
+	}
+
+	return nil
+}
+
+// normalizeOperator defaults an empty operator to AND and rejects unknown ones
+func normalizeOperator(op *domain.Operator, param string) error {
+	switch *op {
+	case "":
+		*op = domain.OperatorAnd
+	case domain.OperatorAnd, domain.OperatorOr:
+	default:
+		return fmt.Errorf("%w: %s must be \"and\" or \"or\", got %q", ErrInvalidFilter, param, *op)
+	}
+	return nil
+}
+
+// ParseTopJournals parses the top_n query parameter, returning 0 (the
+// default) when it is absent or not a number
+func ParseTopJournals(queryParams map[string][]string) int {
+	if topN := queryParams["top_n"]; len(topN) > 0 && topN[0] != "" {
+		if n, err := strconv.Atoi(topN[0]); err == nil {
+			return n
+		}
+	}
+	return 0
 }
 
 // ParseSearchFilters parses query parameters into SearchFilters
@@ -76,18 +331,49 @@ func ParseSearchFilters(queryParams map[string][]string) *domain.SearchFilters {
 		filters.Query = q[0]
 	}
 
+	// year is shorthand for year_from and year_to set to the same year
 	if yearStr := queryParams["year"]; len(yearStr) > 0 && yearStr[0] != "" {
 		if year, err := strconv.Atoi(yearStr[0]); err == nil {
-			filters.Year = &year
+			from, to := year, year
+			filters.YearFrom, filters.YearTo = &from, &to
+		}
+	}
+
+	if yearStr := queryParams["year_from"]; len(yearStr) > 0 && yearStr[0] != "" {
+		if year, err := strconv.Atoi(yearStr[0]); err == nil {
+			filters.YearFrom = &year
 		}
 	}
 
-	if journal := queryParams["journal"]; len(journal) > 0 && journal[0] != "" {
-		filters.Journal = journal[0]
+	if yearStr := queryParams["year_to"]; len(yearStr) > 0 && yearStr[0] != "" {
+		if year, err := strconv.Atoi(yearStr[0]); err == nil {
+			filters.YearTo = &year
+		}
+	}
+
+	filters.Journals = nonEmptyValues(queryParams["journal"])
+	filters.Authors = nonEmptyValues(queryParams["author"])
+
+	if op := queryParams["author_op"]; len(op) > 0 && op[0] != "" {
+		filters.AuthorOperator = domain.Operator(strings.ToLower(op[0]))
+	}
+
+	filters.Mesh = nonEmptyValues(queryParams["mesh"])
+
+	if op := queryParams["mesh_op"]; len(op) > 0 && op[0] != "" {
+		filters.MeshOperator = domain.Operator(strings.ToLower(op[0]))
 	}
 
-	if author := queryParams["author"]; len(author) > 0 && author[0] != "" {
-		filters.Author = author[0]
+	if include := queryParams["include_mesh"]; len(include) > 0 && include[0] != "" {
+		if includeMesh, err := strconv.ParseBool(include[0]); err == nil {
+			filters.IncludeMesh = includeMesh
+		}
+	}
+
+	if explode := queryParams["mesh_explode"]; len(explode) > 0 && explode[0] != "" {
+		if meshExplode, err := strconv.ParseBool(explode[0]); err == nil {
+			filters.MeshExplode = meshExplode
+		}
 	}
 
 	if pageStr := queryParams["page"]; len(pageStr) > 0 && pageStr[0] != "" {
@@ -106,5 +392,32 @@ func ParseSearchFilters(queryParams map[string][]string) *domain.SearchFilters {
 		filters.Sort = sort[0]
 	}
 
+	if cursor := queryParams["cursor"]; len(cursor) > 0 && cursor[0] != "" {
+		filters.Cursor = cursor[0]
+	}
+
+	// facets=journal,year and facets=journal&facets=year are equivalent
+	seenFacets := map[string]bool{}
+	for _, value := range queryParams["facets"] {
+		for _, facet := range strings.Split(value, ",") {
+			facet = strings.TrimSpace(facet)
+			if facet != "" && !seenFacets[facet] {
+				seenFacets[facet] = true
+				filters.Facets = append(filters.Facets, facet)
+			}
+		}
+	}
+
 	return filters
 }
+
+// nonEmptyValues returns the non-empty values of a repeated query parameter
+func nonEmptyValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
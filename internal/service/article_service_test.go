@@ -3,14 +3,23 @@ package service
 import (
 	"context"
 	"errors"
+	"io"
+	"log/slog"
 	"pubmed-api/internal/domain"
+	"pubmed-api/internal/mesh"
+	"pubmed-api/internal/repo"
+	"strconv"
 	"strings"
 	"testing"
 )
 
 // mockRepository is a mock implementation of ArticleRepository
 type mockRepository struct {
-	articles map[string]*domain.Article
+	articles     map[string]*domain.Article
+	lastFilters  *domain.SearchFilters
+	lastStatsTop int
+	// findByIDsCalls counts the batch lookups, which must be one per request
+	findByIDsCalls int
 }
 
 func newMockRepository() *mockRepository {
@@ -27,9 +36,23 @@ func (m *mockRepository) FindByID(ctx context.Context, pmid string) (*domain.Art
 	return article, nil
 }
 
+func (m *mockRepository) FindByIDs(ctx context.Context, pmids []string) ([]*domain.Article, error) {
+	m.findByIDsCalls++
+
+	var articles []*domain.Article
+	for _, pmid := range pmids {
+		if article, ok := m.articles[pmid]; ok {
+			articles = append(articles, article)
+		}
+	}
+	return articles, nil
+}
+
 func (m *mockRepository) Search(ctx context.Context, filters *domain.SearchFilters) (*domain.SearchResult, error) {
+	m.lastFilters = filters
+
 	// Simple mock search implementation
-	var results []*domain.Article
+	var results []*domain.SearchHit
 	for _, article := range m.articles {
 		matches := true
 
@@ -42,18 +65,31 @@ func (m *mockRepository) Search(ctx context.Context, filters *domain.SearchFilte
 			}
 		}
 
-		if filters.Year != nil && article.PubYear != *filters.Year {
+		if filters.YearFrom != nil && article.PubYear < *filters.YearFrom {
 			matches = false
 		}
 
-		if filters.Journal != "" && article.Journal != filters.Journal {
+		if filters.YearTo != nil && article.PubYear > *filters.YearTo {
 			matches = false
 		}
 
-		if filters.Author != "" {
+		if len(filters.Journals) > 0 {
+			found := false
+			for _, journal := range filters.Journals {
+				if article.Journal == journal {
+					found = true
+					break
+				}
+			}
+			if !found {
+				matches = false
+			}
+		}
+
+		for _, name := range filters.Authors {
 			found := false
 			for _, author := range article.Authors {
-				if strings.Contains(author, filters.Author) {
+				if strings.Contains(author, name) {
 					found = true
 					break
 				}
@@ -64,19 +100,19 @@ func (m *mockRepository) Search(ctx context.Context, filters *domain.SearchFilte
 		}
 
 		if matches {
-			results = append(results, article)
+			results = append(results, &domain.SearchHit{Article: article})
 		}
 	}
 
-	// Apply pagination
-	offset := (filters.Page - 1) * filters.PageSize
+	// Apply pagination; keyset pages start at the beginning
+	offset := max(filters.Page-1, 0) * filters.PageSize
 	end := offset + filters.PageSize
 	if end > len(results) {
 		end = len(results)
 	}
 
 	if offset >= len(results) {
-		results = []*domain.Article{}
+		results = []*domain.SearchHit{}
 	} else {
 		results = results[offset:end]
 	}
@@ -85,12 +121,14 @@ func (m *mockRepository) Search(ctx context.Context, filters *domain.SearchFilte
 		Items:    results,
 		Page:     filters.Page,
 		PageSize: filters.PageSize,
-		Total:    len(m.articles),
+		Total:    intPtr(len(m.articles)),
 		TookMs:   1,
 	}, nil
 }
 
-func (m *mockRepository) GetStats(ctx context.Context) (*domain.Stats, error) {
+func (m *mockRepository) GetStats(ctx context.Context, filters *domain.SearchFilters, topJournals int) (*domain.Stats, error) {
+	m.lastStatsTop = topJournals
+
 	return &domain.Stats{
 		TopJournals:   []domain.JournalCount{{Journal: "Test Journal", Count: 5}},
 		YearHistogram: map[int]int{2020: 3, 2021: 2},
@@ -208,7 +246,8 @@ func TestArticleService_SearchArticles(t *testing.T) {
 		{
 			name: "filter by year",
 			filters: &domain.SearchFilters{
-				Year:     intPtr(2020),
+				YearFrom: intPtr(2020),
+				YearTo:   intPtr(2020),
 				Page:     1,
 				PageSize: 10,
 				Sort:     "relevance",
@@ -240,8 +279,8 @@ func TestArticleService_SearchArticles(t *testing.T) {
 				t.Errorf("expected %d items but got %d", tt.expectedCount, len(result.Items))
 			}
 
-			if result.Total != tt.expectedTotal {
-				t.Errorf("expected total %d but got %d", tt.expectedTotal, result.Total)
+			if result.Total == nil || *result.Total != tt.expectedTotal {
+				t.Errorf("expected total %d but got %v", tt.expectedTotal, result.Total)
 			}
 		})
 	}
@@ -251,3 +290,347 @@ func intPtr(i int) *int {
 	return &i
 }
 
+
+func TestParseSearchFilters_Facets(t *testing.T) {
+	filters := ParseSearchFilters(map[string][]string{
+		"facets": {"journal, year", "mesh,journal"},
+	})
+
+	expected := []string{"journal", "year", "mesh"}
+	if strings.Join(filters.Facets, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected facets %v but got %v", expected, filters.Facets)
+	}
+}
+
+func TestArticleService_SearchArticles_InvalidFacet(t *testing.T) {
+	service := NewArticleService(newMockRepository())
+
+	_, err := service.SearchArticles(context.Background(), &domain.SearchFilters{
+		Facets: []string{"journal", "color"},
+	})
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter but got %v", err)
+	}
+}
+
+func TestArticleService_GetStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		topJournals int
+		expectedTop int
+	}{
+		{name: "default", topJournals: 0, expectedTop: DefaultTopJournals},
+		{name: "custom", topJournals: 20, expectedTop: 20},
+		{name: "capped", topJournals: 1000, expectedTop: MaxTopJournals},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := newMockRepository()
+			service := NewArticleService(mockRepo)
+
+			filters := &domain.SearchFilters{Query: "ibuprofen AND pain"}
+			if _, err := service.GetStats(context.Background(), filters, tt.topJournals); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if mockRepo.lastStatsTop != tt.expectedTop {
+				t.Errorf("expected top %d but got %d", tt.expectedTop, mockRepo.lastStatsTop)
+			}
+			if filters.Expr == nil {
+				t.Errorf("expected query to be parsed")
+			}
+		})
+	}
+}
+
+func TestParseSearchFilters_MultiValue(t *testing.T) {
+	filters := ParseSearchFilters(map[string][]string{
+		"year_from":    {"2018"},
+		"year_to":      {"2021"},
+		"journal":      {"J Clin Pharm", "", "Pain Medicine"},
+		"author":       {"Smith J", "Lee K"},
+		"author_op":    {"OR"},
+		"mesh":         {"Ibuprofen", "Pain"},
+		"mesh_op":      {"or"},
+		"include_mesh": {"true"},
+		"mesh_explode": {"1"},
+	})
+
+	if filters.YearFrom == nil || *filters.YearFrom != 2018 || filters.YearTo == nil || *filters.YearTo != 2021 {
+		t.Errorf("expected years 2018-2021 but got %v-%v", filters.YearFrom, filters.YearTo)
+	}
+	if strings.Join(filters.Journals, "|") != "J Clin Pharm|Pain Medicine" {
+		t.Errorf("unexpected journals %v", filters.Journals)
+	}
+	if strings.Join(filters.Authors, "|") != "Smith J|Lee K" {
+		t.Errorf("unexpected authors %v", filters.Authors)
+	}
+	if filters.AuthorOperator != domain.OperatorOr {
+		t.Errorf("expected author operator or but got %q", filters.AuthorOperator)
+	}
+	if strings.Join(filters.Mesh, "|") != "Ibuprofen|Pain" || filters.MeshOperator != domain.OperatorOr {
+		t.Errorf("unexpected mesh filter %v (%q)", filters.Mesh, filters.MeshOperator)
+	}
+	if !filters.IncludeMesh {
+		t.Errorf("expected include_mesh to be parsed")
+	}
+	if !filters.MeshExplode {
+		t.Errorf("expected mesh_explode to be parsed")
+	}
+
+	filters = ParseSearchFilters(map[string][]string{"year": {"2020"}})
+	if filters.YearFrom == nil || *filters.YearFrom != 2020 || filters.YearTo == nil || *filters.YearTo != 2020 {
+		t.Errorf("expected year shorthand to set both bounds")
+	}
+}
+
+func TestArticleService_SearchArticles_InvalidFilters(t *testing.T) {
+	service := NewArticleService(newMockRepository())
+
+	tests := []struct {
+		name    string
+		filters *domain.SearchFilters
+	}{
+		{name: "inverted years", filters: &domain.SearchFilters{YearFrom: intPtr(2022), YearTo: intPtr(2020)}},
+		{name: "unknown author operator", filters: &domain.SearchFilters{Authors: []string{"Smith"}, AuthorOperator: "xor"}},
+		{name: "unknown mesh operator", filters: &domain.SearchFilters{Mesh: []string{"Pain"}, MeshOperator: "not"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.SearchArticles(context.Background(), tt.filters)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("expected ErrInvalidFilter but got %v", err)
+			}
+		})
+	}
+}
+
+func TestArticleService_SearchArticles_Cursor(t *testing.T) {
+	mockRepo := newMockRepository()
+	service := NewArticleService(mockRepo)
+
+	text, err := domain.Cursor{Sort: "year_desc", Year: 2020, PMID: "12"}.MarshalText()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cursor := string(text)
+
+	// A cursor switches to keyset pagination
+	filters := ParseSearchFilters(map[string][]string{"sort": {"year_desc"}, "cursor": {cursor}, "page": {"3"}})
+	if _, err := service.SearchArticles(context.Background(), filters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	after := mockRepo.lastFilters.After
+	if after == nil || *after != (domain.Cursor{Sort: "year_desc", Year: 2020, PMID: "12"}) {
+		t.Errorf("expected the decoded cursor but got %+v", after)
+	}
+	if mockRepo.lastFilters.Page != 0 {
+		t.Errorf("expected the page to be ignored but got %d", mockRepo.lastFilters.Page)
+	}
+
+	tests := []struct {
+		name    string
+		filters *domain.SearchFilters
+	}{
+		{name: "malformed", filters: &domain.SearchFilters{Cursor: "garbage", Sort: "year_desc"}},
+		{name: "other sort", filters: &domain.SearchFilters{Cursor: cursor, Sort: "relevance"}},
+		{name: "default sort", filters: &domain.SearchFilters{Cursor: cursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.SearchArticles(context.Background(), tt.filters)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("expected ErrInvalidFilter but got %v", err)
+			}
+		})
+	}
+}
+
+func TestArticleService_ExportArticles(t *testing.T) {
+	// Enough articles to take several batches
+	memRepo := repo.NewMemoryRepository(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	articles := make([]*domain.Article, 2*exportBatchSize+3)
+	for i := range articles {
+		articles[i] = &domain.Article{
+			PMID:    strconv.Itoa(100000 + i),
+			Title:   "Ibuprofen trial " + strconv.Itoa(i),
+			Journal: "Test Journal",
+			PubYear: 2000 + i%20,
+		}
+	}
+	if _, err := memRepo.InsertArticles(context.Background(), articles); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	service := NewArticleService(memRepo)
+
+	filters := ParseSearchFilters(map[string][]string{"q": {"ibuprofen"}, "sort": {"year_asc"}, "page": {"2"}, "page_size": {"5"}})
+	var exported []*domain.SearchHit
+	err := service.ExportArticles(context.Background(), filters, func(hit *domain.SearchHit) error {
+		exported = append(exported, hit)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(exported) != len(articles) {
+		t.Fatalf("expected %d articles but got %d", len(articles), len(exported))
+	}
+	for i := 1; i < len(exported); i++ {
+		prev, cur := exported[i-1], exported[i]
+		if prev.PubYear > cur.PubYear || (prev.PubYear == cur.PubYear && prev.PMID >= cur.PMID) {
+			t.Fatalf("articles %s and %s are out of order", prev.PMID, cur.PMID)
+		}
+	}
+
+	// An error from fn stops the export
+	stop := errors.New("stop")
+	count := 0
+	err = service.ExportArticles(context.Background(), &domain.SearchFilters{}, func(hit *domain.SearchHit) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || count != 3 {
+		t.Errorf("expected to stop after 3 articles with %v but got %d and %v", stop, count, err)
+	}
+
+	// So does canceling the context, at the next batch
+	ctx, cancel := context.WithCancel(context.Background())
+	count = 0
+	err = service.ExportArticles(ctx, &domain.SearchFilters{}, func(hit *domain.SearchHit) error {
+		count++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || count != exportBatchSize {
+		t.Errorf("expected to stop after one batch with context.Canceled but got %d and %v", count, err)
+	}
+
+	// Invalid filters are reported before anything is exported
+	err = service.ExportArticles(context.Background(), &domain.SearchFilters{Query: "(ibuprofen"}, func(hit *domain.SearchHit) error {
+		t.Fatal("unexpected article")
+		return nil
+	})
+	var syntaxErr *QuerySyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a QuerySyntaxError but got %v", err)
+	}
+}
+
+func TestArticleService_BatchGetArticles(t *testing.T) {
+	mockRepo := newMockRepository()
+	mockRepo.articles["1"] = &domain.Article{PMID: "1", Title: "One"}
+	mockRepo.articles["2"] = &domain.Article{PMID: "2", Title: "Two"}
+	mockRepo.articles["3"] = &domain.Article{PMID: "3", Title: "Three"}
+	service := NewArticleService(mockRepo)
+
+	result, err := service.BatchGetArticles(context.Background(), []string{"3", " 1", "404", "3", "", "2", "405"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var pmids []string
+	for _, article := range result.Articles {
+		pmids = append(pmids, article.PMID)
+	}
+	if strings.Join(pmids, ",") != "3,1,2" {
+		t.Errorf("expected articles 3,1,2 in request order but got %v", pmids)
+	}
+	if strings.Join(result.Missing, ",") != "404,405" {
+		t.Errorf("expected 404,405 missing but got %v", result.Missing)
+	}
+	if mockRepo.findByIDsCalls != 1 {
+		t.Errorf("expected one repository query but got %d", mockRepo.findByIDsCalls)
+	}
+
+	result, err = service.BatchGetArticles(context.Background(), []string{"1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Missing == nil || len(result.Missing) != 0 {
+		t.Errorf("expected an empty missing list but got %#v", result.Missing)
+	}
+
+	tooMany := make([]string, MaxBatchPMIDs+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i + 1)
+	}
+	for _, pmids := range [][]string{nil, {"", " "}, tooMany} {
+		if _, err := service.BatchGetArticles(context.Background(), pmids); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("expected ErrInvalidFilter for %d pmids but got %v", len(pmids), err)
+		}
+	}
+}
+
+func TestParsePMIDs(t *testing.T) {
+	pmids, ok := ParsePMIDs(map[string][]string{"pmid": {"1,2", "3"}})
+	if !ok || strings.Join(pmids, ",") != "1,2,3" {
+		t.Errorf("expected 1,2,3 but got %v (%v)", pmids, ok)
+	}
+
+	if _, ok := ParsePMIDs(map[string][]string{"q": {"pain"}}); ok {
+		t.Error("expected no pmid parameter")
+	}
+}
+
+func TestArticleService_SearchArticles_MeshExplode(t *testing.T) {
+	tree, err := mesh.ParseTree(strings.NewReader(`Anti-Inflammatory Agents	D27.505.954.158
+Anti-Inflammatory Agents, Non-Steroidal	D27.505.954.158.200
+Ibuprofen	D27.505.954.158.200.400
+Aspirin	D27.505.954.158.200.100
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mockRepo := newMockRepository()
+	service := NewArticleService(mockRepo, WithMeshTree(tree))
+
+	filters := &domain.SearchFilters{Mesh: []string{"Anti-Inflammatory Agents, Non-Steroidal", "Ibuprofen"}, MeshExplode: true}
+	if _, err := service.SearchArticles(context.Background(), filters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expansions := mockRepo.lastFilters.MeshExpansions
+	if got := strings.Join(expansions["Anti-Inflammatory Agents, Non-Steroidal"], "|"); got != "Aspirin|Ibuprofen" {
+		t.Errorf("expected Aspirin|Ibuprofen but got %q", got)
+	}
+	if _, ok := expansions["Ibuprofen"]; ok {
+		t.Errorf("expected no expansion for a leaf term")
+	}
+
+	// Without explode the filter is left as is
+	filters = &domain.SearchFilters{Mesh: []string{"Anti-Inflammatory Agents"}}
+	if _, err := service.SearchArticles(context.Background(), filters); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRepo.lastFilters.MeshExpansions != nil {
+		t.Errorf("expected no expansions but got %v", mockRepo.lastFilters.MeshExpansions)
+	}
+
+	// Exploding needs a tree
+	filters = &domain.SearchFilters{Mesh: []string{"Pain"}, MeshExplode: true}
+	if _, err := NewArticleService(mockRepo).SearchArticles(context.Background(), filters); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter but got %v", err)
+	}
+}
+
+func TestArticleService_GetLoadReport(t *testing.T) {
+	if _, err := NewArticleService(newMockRepository()).GetLoadReport(context.Background()); !errors.Is(err, ErrNoLoadReport) {
+		t.Errorf("expected ErrNoLoadReport but got %v", err)
+	}
+
+	report := &domain.LoadReport{Source: "embedded", Accepted: 2}
+	got, err := NewArticleService(newMockRepository(), WithLoadReport(report)).GetLoadReport(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != report {
+		t.Errorf("expected the configured report but got %v", got)
+	}
+}
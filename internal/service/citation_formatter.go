@@ -0,0 +1,188 @@
+package service
+
+import (
+	"fmt"
+	"pubmed-api/internal/citation"
+	"pubmed-api/internal/domain"
+	"strconv"
+	"strings"
+)
+
+// Citation styles supported by CitationFormatter
+const (
+	StyleAMA       = "ama"
+	StyleAPA       = "apa"
+	StyleVancouver = "vancouver"
+)
+
+// DefaultCitationStyle is the style used when none is requested
+const DefaultCitationStyle = StyleVancouver
+
+// styleAuthorLimits are the author list rules of each style: lists longer
+// than max are cut to listed authors followed by "et al.". APA (7th edition)
+// instead lists the first 19 authors, an ellipsis and the last author.
+var styleAuthorLimits = map[string]struct{ max, listed int }{
+	StyleAMA:       {max: 6, listed: 3},
+	StyleAPA:       {max: 20, listed: 19},
+	StyleVancouver: {max: 6, listed: 6},
+}
+
+// CitationFormatter renders articles as human-readable citations in a
+// citation style. Articles carry no volume, issue or pages, so citations end
+// with the year and DOI.
+type CitationFormatter struct {
+	style      string
+	maxAuthors int
+	listed     int
+	// etAl is false when truncated APA lists end with the last author
+	etAl bool
+}
+
+// NewCitationFormatter returns a formatter for style. maxAuthors overrides
+// the style's truncation rule when positive: longer author lists are cut to
+// maxAuthors names followed by "et al.".
+func NewCitationFormatter(style string, maxAuthors int) (*CitationFormatter, error) {
+	if style == "" {
+		style = DefaultCitationStyle
+	}
+	style = strings.ToLower(style)
+
+	limits, ok := styleAuthorLimits[style]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown citation style %q", ErrInvalidFilter, style)
+	}
+
+	f := &CitationFormatter{style: style, maxAuthors: limits.max, listed: limits.listed, etAl: style != StyleAPA}
+	if maxAuthors > 0 {
+		f.maxAuthors, f.listed, f.etAl = maxAuthors, maxAuthors, true
+	}
+	return f, nil
+}
+
+// ParseCitationFormatter returns a formatter for the style and max_authors
+// query parameters
+func ParseCitationFormatter(queryParams map[string][]string) (*CitationFormatter, error) {
+	var style string
+	if s := queryParams["style"]; len(s) > 0 {
+		style = s[0]
+	}
+
+	maxAuthors := 0
+	if m := queryParams["max_authors"]; len(m) > 0 && m[0] != "" {
+		n, err := strconv.Atoi(m[0])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%w: max_authors must be a positive number, got %q", ErrInvalidFilter, m[0])
+		}
+		maxAuthors = n
+	}
+
+	return NewCitationFormatter(style, maxAuthors)
+}
+
+// Style returns the name of the formatter's style
+func (f *CitationFormatter) Style() string {
+	return f.style
+}
+
+// Format returns the citation of an article
+func (f *CitationFormatter) Format(article *domain.Article) string {
+	var parts []string
+	add := func(s string) {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+
+	switch f.style {
+	case StyleAPA:
+		// APA puts the title first when there are no authors
+		year := "(n.d.)."
+		if article.PubYear != 0 {
+			year = "(" + strconv.Itoa(article.PubYear) + ")."
+		}
+		if authors := f.apaAuthors(article.Authors); authors != "" {
+			add(authors)
+			add(year)
+			add(sentence(article.Title))
+		} else {
+			add(sentence(article.Title))
+			add(year)
+		}
+		add(sentence(article.Journal))
+		if article.DOI != "" {
+			add("https://doi.org/" + article.DOI)
+		}
+
+	default:
+		// AMA and Vancouver differ in truncation and in how they end
+		add(sentence(f.nlmAuthors(article.Authors)))
+		add(sentence(article.Title))
+		add(sentence(article.Journal))
+		if article.PubYear != 0 {
+			add(strconv.Itoa(article.PubYear) + ".")
+		}
+		if f.style == StyleAMA {
+			if article.DOI != "" {
+				add("doi:" + article.DOI)
+			}
+		} else {
+			if article.DOI != "" {
+				add("doi: " + article.DOI + ".")
+			}
+			add("PMID: " + article.PMID + ".")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// nlmAuthors lists authors as "Smith JA, Lee K", the form of AMA and
+// Vancouver
+func (f *CitationFormatter) nlmAuthors(authors []string) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		name := citation.ParseName(author)
+		if name.Literal != "" {
+			names = append(names, name.Literal)
+		} else {
+			names = append(names, name.Family+" "+name.Initials)
+		}
+	}
+
+	if len(names) > f.maxAuthors {
+		names = append(names[:f.listed:f.listed], "et al")
+	}
+	return strings.Join(names, ", ")
+}
+
+// apaAuthors lists authors as "Smith, J. A., & Lee, K."
+func (f *CitationFormatter) apaAuthors(authors []string) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		names = append(names, citation.ParseName(author).Inverted())
+	}
+
+	var list string
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) > f.maxAuthors && f.etAl:
+		list = strings.Join(names[:f.listed], ", ") + ", et al"
+	case len(names) > f.maxAuthors:
+		list = strings.Join(names[:f.listed], ", ") + ", . . . " + names[len(names)-1]
+	case len(names) == 1:
+		list = names[0]
+	default:
+		list = strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+	}
+	return sentence(list)
+}
+
+// sentence ends s with a period unless it already ends a sentence
+func sentence(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" || strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
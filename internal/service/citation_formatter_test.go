@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"pubmed-api/internal/domain"
+	"strconv"
+	"testing"
+)
+
+func TestCitationFormatter_Format(t *testing.T) {
+	article := &domain.Article{
+		PMID:    "31234567",
+		Title:   "Ibuprofen versus paracetamol for acute postoperative pain",
+		Authors: []string{"Smith JA", "van der Berg K", "POP Trial Investigators"},
+		Journal: "Br J Clin Pharmacol",
+		PubYear: 2020,
+		DOI:     "10.1111/bcp.14180",
+	}
+
+	// 21 authors, more than any style lists in full
+	many := &domain.Article{PMID: "1", Title: "Many hands?", Journal: "J Test", PubYear: 2021}
+	for i := 1; i <= 21; i++ {
+		many.Authors = append(many.Authors, "Author"+strconv.Itoa(i)+" A")
+	}
+
+	bare := &domain.Article{PMID: "2", Title: "Anonymous report", Journal: "J Test"}
+
+	tests := []struct {
+		name       string
+		style      string
+		maxAuthors int
+		article    *domain.Article
+		want       string
+	}{
+		{
+			name:    "vancouver",
+			style:   StyleVancouver,
+			article: article,
+			want:    "Smith JA, van der Berg K, POP Trial Investigators. Ibuprofen versus paracetamol for acute postoperative pain. Br J Clin Pharmacol. 2020. doi: 10.1111/bcp.14180. PMID: 31234567.",
+		},
+		{
+			name:    "ama",
+			style:   StyleAMA,
+			article: article,
+			want:    "Smith JA, van der Berg K, POP Trial Investigators. Ibuprofen versus paracetamol for acute postoperative pain. Br J Clin Pharmacol. 2020. doi:10.1111/bcp.14180",
+		},
+		{
+			name:    "apa",
+			style:   StyleAPA,
+			article: article,
+			want:    "Smith, J. A., van der Berg, K., & POP Trial Investigators. (2020). Ibuprofen versus paracetamol for acute postoperative pain. Br J Clin Pharmacol. https://doi.org/10.1111/bcp.14180",
+		},
+		{
+			name:    "vancouver truncates after six",
+			style:   StyleVancouver,
+			article: many,
+			want:    "Author1 A, Author2 A, Author3 A, Author4 A, Author5 A, Author6 A, et al. Many hands? J Test. 2021. PMID: 1.",
+		},
+		{
+			name:    "ama truncates to three",
+			style:   StyleAMA,
+			article: many,
+			want:    "Author1 A, Author2 A, Author3 A, et al. Many hands? J Test. 2021.",
+		},
+		{
+			name:    "apa lists nineteen and the last",
+			style:   StyleAPA,
+			article: many,
+			want: "Author1, A., Author2, A., Author3, A., Author4, A., Author5, A., Author6, A., Author7, A., Author8, A., Author9, A., Author10, A., " +
+				"Author11, A., Author12, A., Author13, A., Author14, A., Author15, A., Author16, A., Author17, A., Author18, A., Author19, A., . . . Author21, A. (2021). Many hands? J Test.",
+		},
+		{
+			name:       "max_authors overrides the style",
+			style:      StyleAPA,
+			maxAuthors: 2,
+			article:    article,
+			want:       "Smith, J. A., van der Berg, K., et al. (2020). Ibuprofen versus paracetamol for acute postoperative pain. Br J Clin Pharmacol. https://doi.org/10.1111/bcp.14180",
+		},
+		{
+			name:    "apa without authors or year",
+			style:   StyleAPA,
+			article: bare,
+			want:    "Anonymous report. (n.d.). J Test.",
+		},
+		{
+			name:    "default style without authors or year",
+			article: bare,
+			want:    "Anonymous report. J Test. PMID: 2.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := NewCitationFormatter(tt.style, tt.maxAuthors)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := formatter.Format(tt.article); got != tt.want {
+				t.Errorf("expected\n%s\nbut got\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseCitationFormatter(t *testing.T) {
+	formatter, err := ParseCitationFormatter(map[string][]string{"style": {"AMA"}, "max_authors": {"4"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if formatter.Style() != StyleAMA || formatter.maxAuthors != 4 {
+		t.Errorf("expected ama with 4 authors but got %s with %d", formatter.Style(), formatter.maxAuthors)
+	}
+
+	formatter, err = ParseCitationFormatter(map[string][]string{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if formatter.Style() != DefaultCitationStyle {
+		t.Errorf("expected the default style but got %s", formatter.Style())
+	}
+
+	for _, params := range []map[string][]string{
+		{"style": {"harvard"}},
+		{"max_authors": {"0"}},
+		{"max_authors": {"many"}},
+	} {
+		if _, err := ParseCitationFormatter(params); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("expected ErrInvalidFilter for %v but got %v", params, err)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"fmt"
+	"pubmed-api/internal/domain"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuerySyntaxError reports a malformed search query
+type QuerySyntaxError struct {
+	// Position is the 1-based character offset of the offending token
+	Position int
+	Message  string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Position, e.Message)
+}
+
+// fieldTags maps PubMed search tags (lowercased) to query fields. [dp] is
+// handled separately since it produces a year range rather than a term.
+var fieldTags = map[string]domain.QueryField{
+	"all":      domain.FieldAll,
+	"tiab":     domain.FieldTitleAbstract,
+	"ti":       domain.FieldTitle,
+	"title":    domain.FieldTitle,
+	"ab":       domain.FieldAbstract,
+	"abstract": domain.FieldAbstract,
+	"au":       domain.FieldAuthor,
+	"author":   domain.FieldAuthor,
+	"ta":       domain.FieldJournal,
+	"journal":  domain.FieldJournal,
+	"mh":       domain.FieldMesh,
+	"mesh":     domain.FieldMesh,
+}
+
+// ParseQuery parses a PubMed-style boolean query into an AST.
+//
+// The language supports AND, OR and NOT (upper case only), parentheses,
+// quoted phrases, trailing '*' truncation and field tags such as aspirin[ti],
+// Smith J[au], Ibuprofen[mh] and 2018:2020[dp]. Words separated only by
+// spaces are ANDed. As in PubMed, operators have equal precedence and are
+// applied left to right, so "a OR b AND c" means "(a OR b) AND c".
+func ParseQuery(query string) (domain.QueryNode, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, end: utf8.RuneCountInString(query) + 1}
+	if p.peek().kind == tokenEOF {
+		return nil, &QuerySyntaxError{Position: 1, Message: "query is empty"}
+	}
+
+	node, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, &QuerySyntaxError{Position: tok.pos, Message: fmt.Sprintf("unexpected %s", tok)}
+	}
+
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenPhrase
+	tokenTag
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenPhrase:
+		return fmt.Sprintf("phrase %q", t.text)
+	case tokenTag:
+		return fmt.Sprintf("field tag [%s]", t.text)
+	case tokenAnd, tokenOr, tokenNot:
+		return "operator " + t.text
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexQuery splits a query into tokens. Runs of words directly followed by a
+// field tag are merged into one word so that "Smith J[au]" is a single term.
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, text: "(", pos: pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, text: ")", pos: pos})
+			i++
+		case r == '"':
+			end := indexRune(runes, i+1, '"')
+			if end < 0 {
+				return nil, &QuerySyntaxError{Position: pos, Message: "unterminated quoted phrase"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, text: string(runes[i+1 : end]), pos: pos})
+			i = end + 1
+		case r == '[':
+			end := indexRune(runes, i+1, ']')
+			if end < 0 {
+				return nil, &QuerySyntaxError{Position: pos, Message: "unterminated field tag"}
+			}
+			tokens = append(tokens, queryToken{kind: tokenTag, text: strings.TrimSpace(string(runes[i+1 : end])), pos: pos})
+			i = end + 1
+		case r == ']':
+			return nil, &QuerySyntaxError{Position: pos, Message: "unexpected ]"}
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"[]`, runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			kind := tokenWord
+			switch word {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: word, pos: pos})
+		}
+	}
+
+	return mergeTaggedWords(tokens), nil
+}
+
+// mergeTaggedWords joins consecutive words that precede a field tag
+func mergeTaggedWords(tokens []queryToken) []queryToken {
+	merged := make([]queryToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].kind != tokenWord {
+			merged = append(merged, tokens[i])
+			continue
+		}
+
+		j := i
+		for j+1 < len(tokens) && tokens[j+1].kind == tokenWord {
+			j++
+		}
+		if j == i || j+1 >= len(tokens) || tokens[j+1].kind != tokenTag {
+			merged = append(merged, tokens[i])
+			continue
+		}
+
+		words := make([]string, 0, j-i+1)
+		for _, tok := range tokens[i : j+1] {
+			words = append(words, tok.text)
+		}
+		merged = append(merged, queryToken{kind: tokenWord, text: strings.Join(words, " "), pos: tokens[i].pos})
+		i = j
+	}
+	return merged
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+	// end is the position reported for errors at the end of the query
+	end int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.next >= len(p.tokens) {
+		return queryToken{kind: tokenEOF, pos: p.end}
+	}
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) advance() queryToken {
+	tok := p.peek()
+	if p.next < len(p.tokens) {
+		p.next++
+	}
+	return tok
+}
+
+// parseExpr parses operands joined by operators, left to right
+func (p *queryParser) parseExpr() (domain.QueryNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		switch op.kind {
+		case tokenAnd, tokenOr, tokenNot:
+			p.advance()
+		case tokenWord, tokenPhrase, tokenLParen:
+			// Juxtaposed operands are ANDed
+			op = queryToken{kind: tokenAnd}
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.kind {
+		case tokenAnd:
+			if and, ok := left.(*domain.QueryAnd); ok {
+				and.Operands = append(and.Operands, right)
+			} else {
+				left = &domain.QueryAnd{Operands: []domain.QueryNode{left, right}}
+			}
+		case tokenOr:
+			if or, ok := left.(*domain.QueryOr); ok {
+				or.Operands = append(or.Operands, right)
+			} else {
+				left = &domain.QueryOr{Operands: []domain.QueryNode{left, right}}
+			}
+		case tokenNot:
+			left = &domain.QueryNot{Include: left, Exclude: right}
+		}
+	}
+}
+
+// parseOperand parses a parenthesized expression or a single term
+func (p *queryParser) parseOperand() (domain.QueryNode, error) {
+	tok := p.advance()
+
+	switch tok.kind {
+	case tokenLParen:
+		node, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, &QuerySyntaxError{Position: closing.pos, Message: fmt.Sprintf("expected ) to close ( at position %d, found %s", tok.pos, closing)}
+		}
+		if next := p.peek(); next.kind == tokenTag {
+			return nil, &QuerySyntaxError{Position: next.pos, Message: "field tags can only follow a term"}
+		}
+		return node, nil
+	case tokenWord, tokenPhrase:
+		var tag *queryToken
+		if next := p.peek(); next.kind == tokenTag {
+			t := p.advance()
+			tag = &t
+		}
+		return newQueryTerm(tok, tag)
+	case tokenTag:
+		return nil, &QuerySyntaxError{Position: tok.pos, Message: "field tags can only follow a term"}
+	default:
+		return nil, &QuerySyntaxError{Position: tok.pos, Message: fmt.Sprintf("expected a term, found %s", tok)}
+	}
+}
+
+// newQueryTerm builds the node for a word or phrase and its optional field tag
+func newQueryTerm(tok queryToken, tag *queryToken) (domain.QueryNode, error) {
+	text := strings.TrimSpace(tok.text)
+
+	field := domain.FieldAll
+	if tag != nil {
+		name := strings.ToLower(tag.text)
+		if name == "dp" || name == "pdat" {
+			return parseYears(text, tok.pos)
+		}
+
+		f, ok := fieldTags[name]
+		if !ok {
+			return nil, &QuerySyntaxError{Position: tag.pos, Message: fmt.Sprintf("unknown field tag [%s]", tag.text)}
+		}
+		field = f
+	}
+
+	term := &domain.QueryTerm{Text: text, Field: field}
+	if field.IsText() && tok.kind == tokenWord && strings.HasSuffix(text, "*") {
+		term.Text = strings.TrimRight(text, "*")
+		term.Prefix = true
+	}
+
+	if !strings.ContainsFunc(term.Text, isWordRune) {
+		return nil, &QuerySyntaxError{Position: tok.pos, Message: fmt.Sprintf("%s has no searchable characters", tok)}
+	}
+
+	return term, nil
+}
+
+// parseYears parses a [dp] value: a year or a colon-separated range of years
+func parseYears(text string, pos int) (domain.QueryNode, error) {
+	fromStr, toStr, isRange := strings.Cut(text, ":")
+	if !isRange {
+		toStr = fromStr
+	}
+
+	from, errFrom := strconv.Atoi(strings.TrimSpace(fromStr))
+	to, errTo := strconv.Atoi(strings.TrimSpace(toStr))
+	if errFrom != nil || errTo != nil {
+		return nil, &QuerySyntaxError{Position: pos, Message: fmt.Sprintf("publication date %q must be a year or a range like 2018:2020", text)}
+	}
+	if from > to {
+		return nil, &QuerySyntaxError{Position: pos, Message: fmt.Sprintf("publication date range %q ends before it starts", text)}
+	}
+
+	return &domain.QueryYears{From: from, To: to}, nil
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
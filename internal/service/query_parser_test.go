@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"pubmed-api/internal/domain"
+	"strings"
+	"testing"
+)
+
+// formatQuery renders an AST in a compact prefix form for comparisons
+func formatQuery(node domain.QueryNode) string {
+	join := func(op string, operands []domain.QueryNode) string {
+		parts := make([]string, 0, len(operands))
+		for _, operand := range operands {
+			parts = append(parts, formatQuery(operand))
+		}
+		return op + "(" + strings.Join(parts, " ") + ")"
+	}
+
+	switch n := node.(type) {
+	case *domain.QueryTerm:
+		s := fmt.Sprintf("%q[%s]", n.Text, n.Field)
+		if n.Prefix {
+			s += "*"
+		}
+		return s
+	case *domain.QueryYears:
+		return fmt.Sprintf("years(%d:%d)", n.From, n.To)
+	case *domain.QueryAnd:
+		return join("and", n.Operands)
+	case *domain.QueryOr:
+		return join("or", n.Operands)
+	case *domain.QueryNot:
+		return "not(" + formatQuery(n.Include) + " " + formatQuery(n.Exclude) + ")"
+	default:
+		return fmt.Sprintf("%T", node)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "ibuprofen", want: `"ibuprofen"[all]`},
+		{query: "ibuprofen pain", want: `and("ibuprofen"[all] "pain"[all])`},
+		{
+			query: "ibuprofen AND (pain OR fever) NOT pediatric",
+			want:  `not(and("ibuprofen"[all] or("pain"[all] "fever"[all])) "pediatric"[all])`,
+		},
+		{query: "a OR b AND c", want: `and(or("a"[all] "b"[all]) "c"[all])`},
+		{query: "a AND b AND c", want: `and("a"[all] "b"[all] "c"[all])`},
+		{query: `"heart attack" aspirin`, want: `and("heart attack"[all] "aspirin"[all])`},
+		{query: "aspirin[ti]", want: `"aspirin"[ti]`},
+		{query: `"low dose aspirin"[TI]`, want: `"low dose aspirin"[ti]`},
+		{query: "Smith J[au]", want: `"Smith J"[au]`},
+		{query: "aspirin Smith J [au]", want: `"aspirin Smith J"[au]`},
+		{query: "J Clin Pharm[ta]", want: `"J Clin Pharm"[ta]`},
+		{query: "Anti-Inflammatory Agents[mh]", want: `"Anti-Inflammatory Agents"[mh]`},
+		{query: "pain[tiab]", want: `"pain"[tiab]`},
+		{query: "2020[dp]", want: `years(2020:2020)`},
+		{query: "aspirin AND 2018:2020[dp]", want: `and("aspirin"[all] years(2018:2020))`},
+		{query: "analges*", want: `"analges"[all]*`},
+		{query: "and or not", want: `and("and"[all] "or"[all] "not"[all])`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := formatQuery(node); got != tt.want {
+				t.Errorf("expected %s but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseQuery_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+		message  string
+	}{
+		{query: "", position: 1, message: "query is empty"},
+		{query: "AND aspirin", position: 1, message: "expected a term"},
+		{query: "aspirin OR", position: 11, message: "expected a term, found end of query"},
+		{query: "(pain OR fever", position: 15, message: "expected ) to close ( at position 1"},
+		{query: "pain)", position: 5, message: "unexpected \")\""},
+		{query: `aspirin "heart`, position: 9, message: "unterminated quoted phrase"},
+		{query: "aspirin[xx]", position: 8, message: "unknown field tag [xx]"},
+		{query: "(a OR b)[ti]", position: 9, message: "field tags can only follow a term"},
+		{query: "recent[dp]", position: 1, message: "must be a year"},
+		{query: "2021:2019[dp]", position: 1, message: "ends before it starts"},
+		{query: "pain AND -", position: 10, message: "no searchable characters"},
+		{query: "naïve OR (", position: 11, message: "expected a term"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+
+			var syntaxErr *QuerySyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("expected QuerySyntaxError but got %v", err)
+			}
+			if syntaxErr.Position != tt.position {
+				t.Errorf("expected position %d but got %d (%s)", tt.position, syntaxErr.Position, syntaxErr.Message)
+			}
+			if !strings.Contains(syntaxErr.Message, tt.message) {
+				t.Errorf("expected message containing %q but got %q", tt.message, syntaxErr.Message)
+			}
+		})
+	}
+}
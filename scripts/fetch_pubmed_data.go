@@ -3,9 +3,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"pubmed-api/internal/pubmed"
 	"strings"
 )
 
@@ -47,15 +47,13 @@ func main() {
 	}
 	defer resp.Body.Close()
 
-	xmlData, err := io.ReadAll(resp.Body)
+	// Parse XML and convert to JSONL
+	citations, err := pubmed.ParseArticleSet(resp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to read response: %v\n", err)
+		fmt.Fprintf(os.Stderr, "failed to parse response: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Parse XML and convert to JSONL
-	articles := parsePubMedXML(xmlData)
-
 	// Write to JSONL file
 	outputFile := "data/sample_100_pubmed.jsonl"
 	if err := os.MkdirAll("data", 0755); err != nil {
@@ -71,58 +69,12 @@ func main() {
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
-	for _, article := range articles {
-		if err := encoder.Encode(article); err != nil {
+	for _, citation := range citations {
+		if err := encoder.Encode(citation.Article()); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to encode article: %v\n", err)
 			continue
 		}
 	}
 
-	fmt.Printf("Successfully fetched and saved %d articles to %s\n", len(articles), outputFile)
-}
-
-// parsePubMedXML is a simplified parser for PubMed XML
-// For production, consider using a proper XML parser
-func parsePubMedXML(data []byte) []map[string]interface{} {
-	// This is a simplified parser - in production, use encoding/xml
-	// For now, we'll create a minimal sample dataset
-	articles := []map[string]interface{}{
-		{
-			"pmid":      "12345678",
-			"title":     "Ibuprofen and its clinical use in pain management",
-			"abstract":  "Ibuprofen is a nonsteroidal anti-inflammatory drug commonly used for pain relief and inflammation reduction.",
-			"authors":   []string{"Smith J", "Lee K"},
-			"journal":   "J Clin Pharm",
-			"pub_year":  2020,
-			"mesh_terms": []string{"Ibuprofen", "Anti-Inflammatory Agents"},
-			"doi":       "10.1000/jcp.2020.1234",
-		},
-		{
-			"pmid":      "12345679",
-			"title":     "Comparative study of ibuprofen and acetaminophen",
-			"abstract":  "This study compares the efficacy of ibuprofen versus acetaminophen in treating postoperative pain.",
-			"authors":   []string{"Johnson A", "Brown M"},
-			"journal":   "Pain Medicine",
-			"pub_year":  2021,
-			"mesh_terms": []string{"Ibuprofen", "Acetaminophen", "Pain Management"},
-			"doi":       "10.1000/pm.2021.5678",
-		},
-	}
-
-	// Add more sample articles to reach ~100
-	for i := 2; i < 100; i++ {
-		articles = append(articles, map[string]interface{}{
-			"pmid":      fmt.Sprintf("1234%04d", 5678+i),
-			"title":     fmt.Sprintf("Ibuprofen research article %d", i),
-			"abstract":  fmt.Sprintf("Abstract for ibuprofen research article number %d discussing various aspects of the drug.", i),
-			"authors":   []string{fmt.Sprintf("Author%d A", i), fmt.Sprintf("Author%d B", i)},
-			"journal":   fmt.Sprintf("Journal %d", i%10+1),
-			"pub_year":  2020 + (i % 3),
-			"mesh_terms": []string{"Ibuprofen", "Research"},
-			"doi":       fmt.Sprintf("10.1000/j%d.%d", i%10+1, 2020+i%3),
-		})
-	}
-
-	return articles
+	fmt.Printf("Successfully fetched and saved %d articles to %s\n", len(citations), outputFile)
 }
-